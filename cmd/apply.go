@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/ui"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Reconcile a fleet with its manifest",
+	Long: `Bring a fleet to the state described by its fleet.yaml: missing boxes
+are spawned and built, extra boxes are deleted, boxes in the wrong region or
+size are replaced and boxes failing the verification of the build recipe
+are rebuilt. Once the TTL of the manifest is over the fleet is deleted.
+
+Examples:
+  fleex apply -f fleet.yaml
+  fleex apply -f fleet.yaml -y`,
+	Run: func(cmd *cobra.Command, args []string) {
+		proxy, _ := rootCmd.PersistentFlags().GetString("proxy")
+		utils.SetProxy(proxy)
+
+		file, _ := cmd.Flags().GetString("file")
+		skipVerify, _ := cmd.Flags().GetBool("skip-verify")
+		autoApprove, _ := cmd.Flags().GetBool("yes")
+
+		newController, plan := loadFleetPlan(file, skipVerify)
+		showFleetPlan(plan)
+		if plan.Empty() {
+			return
+		}
+
+		if !autoApprove {
+			fmt.Print("\nApply these changes? [y/N]: ")
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			if answer != "y" && answer != "yes" {
+				fmt.Println("Apply cancelled")
+				return
+			}
+		}
+
+		if err := newController.ApplyFleet(plan); err != nil {
+			utils.Log.Fatal(err)
+		}
+		ui.Success(fmt.Sprintf("Fleet %s applied", plan.Manifest.Name))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringP("file", "f", "", "Fleet manifest file")
+	applyCmd.Flags().BoolP("yes", "y", false, "Apply without asking for confirmation")
+	applyCmd.Flags().BoolP("skip-verify", "", false, "Don't check boxes against the verification of the build recipe")
+}
@@ -88,6 +88,11 @@ var buildShowCmd = &cobra.Command{
 			}
 		}
 
+		if len(recipe.Params) > 0 {
+			fmt.Println("\nParameters:")
+			printParams(recipe.Params)
+		}
+
 		if len(recipe.Files) > 0 {
 			fmt.Println("\nFiles:")
 			for _, f := range recipe.Files {
@@ -137,6 +142,7 @@ var buildRunCmd = &cobra.Command{
 		continueErr, _ := cmd.Flags().GetBool("continue")
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		replaceFailed, _ := cmd.Flags().GetBool("replace-failed")
 
 		if recipeName == "" && recipeFile == "" {
 			utils.Log.Fatal("Either --recipe or --file is required")
@@ -159,6 +165,15 @@ var buildRunCmd = &cobra.Command{
 			utils.Log.Fatal("Failed to load recipe: ", err)
 		}
 
+		paramsFlag, _ := cmd.Flags().GetStringSlice("params")
+		if recipe.Vars == nil {
+			recipe.Vars = make(map[string]string)
+		}
+		utils.ApplyParamFlags(recipe.Vars, paramsFlag)
+		if err := utils.ApplyParams(recipe.Params, recipe.Vars); err != nil {
+			utils.Log.Fatal("Invalid recipe params: ", err)
+		}
+
 		provider := controller.GetProvider(globalConfig.Settings.Provider)
 		if provider == -1 {
 			utils.Log.Fatal(models.ErrInvalidProvider)
@@ -212,13 +227,14 @@ var buildRunCmd = &cobra.Command{
 		fmt.Printf("Building fleet '%s' (%d instances) with recipe '%s'...\n", fleetName, len(fleet), recipe.Name)
 
 		opts := models.BuildOptions{
-			Recipe:      recipe,
-			FleetName:   fleetName,
-			Parallel:    parallel,
-			NoVerify:    noVerify,
-			ContinueErr: continueErr,
-			DryRun:      dryRun,
-			Verbose:     verbose,
+			Recipe:        recipe,
+			FleetName:     fleetName,
+			Parallel:      parallel,
+			NoVerify:      noVerify,
+			ContinueErr:   continueErr,
+			DryRun:        dryRun,
+			Verbose:       verbose,
+			ReplaceFailed: replaceFailed,
 		}
 
 		results, err := newController.BuildFleet(opts)
@@ -227,13 +243,20 @@ var buildRunCmd = &cobra.Command{
 		}
 
 		successCount := 0
+		var replaced []string
 		for _, r := range results {
 			if r.Success {
 				successCount++
 			}
+			if r.Replaced {
+				replaced = append(replaced, r.BoxName)
+			}
 		}
 
 		fmt.Printf("\nBuild complete: %d/%d successful\n", successCount, len(results))
+		if len(replaced) > 0 {
+			fmt.Printf("Replaced boxes: %s\n", strings.Join(replaced, ", "))
+		}
 
 		if snapshot && successCount > 0 {
 			createSnapshot()
@@ -384,6 +407,8 @@ func init() {
 	buildRunCmd.Flags().BoolP("continue", "", false, "Continue on step failure")
 	buildRunCmd.Flags().BoolP("dry-run", "", false, "Show what would be executed")
 	buildRunCmd.Flags().BoolP("verbose", "v", false, "Show detailed output")
+	buildRunCmd.Flags().BoolP("replace-failed", "", false, "Replace boxes that fail the build with new boxes and build them again")
+	buildRunCmd.Flags().StringSliceP("params", "", []string{}, "Set recipe parameters in the format KEY:VALUE")
 
 	buildVerifyCmd.Flags().StringP("recipe", "r", "", "Build recipe name")
 	buildVerifyCmd.Flags().StringP("name", "n", "", "Fleet name to verify")
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/controller"
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scheduled jobs",
+	Long: `Run the jobs defined with 'fleex schedule' when they are due.
+
+Job definitions are reloaded every minute, so jobs can be added or removed
+while the daemon is running. A job is skipped if its previous run is still
+in progress.
+
+Fleets applied from a manifest with a TTL are deleted once it is over.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		proxy, _ := rootCmd.PersistentFlags().GetString("proxy")
+		utils.SetProxy(proxy)
+
+		var running sync.Map
+		last := time.Now().Truncate(time.Minute)
+
+		utils.Log.Info("Fleex daemon started")
+
+		for {
+			now := time.Now().Truncate(time.Minute)
+			if now.After(last) {
+				for _, job := range dueJobs(last, now) {
+					if _, busy := running.LoadOrStore(job.Name, true); busy {
+						utils.Log.Warnf("[%s] Previous run still in progress, skipping", job.Name)
+						utils.AppendScheduleRun(models.ScheduleRun{
+							Job:      job.Name,
+							Started:  time.Now(),
+							Finished: time.Now(),
+							Skipped:  true,
+							Error:    "previous run still in progress",
+						}, job.Retention)
+						continue
+					}
+
+					go func(job models.ScheduledJob) {
+						defer running.Delete(job.Name)
+						utils.Log.Infof("[%s] Starting scheduled run", job.Name)
+						run := runScheduledJob(job)
+						if run.Success {
+							utils.Log.Infof("[%s] Run complete: %d/%d successful", job.Name, run.Successful, run.Boxes)
+						} else {
+							utils.Log.Errorf("[%s] Run failed: %s", job.Name, run.Error)
+						}
+					}(job)
+				}
+				last = now
+
+				controller.ExpireFleets(globalConfig)
+			}
+
+			time.Sleep(time.Until(now.Add(time.Minute)))
+		}
+	},
+}
+
+// dueJobs returns the jobs scheduled to run in the (from, to] interval
+func dueJobs(from, to time.Time) []models.ScheduledJob {
+	names, err := utils.ListSchedules()
+	if err != nil {
+		utils.Log.Error(err)
+		return nil
+	}
+
+	var jobs []models.ScheduledJob
+	for _, name := range names {
+		job, err := utils.ReadScheduleFile(name)
+		if err != nil {
+			utils.Log.Errorf("[%s] %v", name, err)
+			continue
+		}
+
+		schedule, err := cron.ParseStandard(job.Cron)
+		if err != nil {
+			utils.Log.Errorf("[%s] Invalid cron expression: %v", name, err)
+			continue
+		}
+
+		if !schedule.Next(from).After(to) {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -164,6 +164,7 @@ func runWizard(reader *bufio.Reader, fleexPath string) {
 
 	createDefaultWorkflows(fleexPath)
 	createDefaultBuilds(fleexPath)
+	createDefaultUserData(fleexPath)
 
 	fmt.Println("\n=== SETUP COMPLETE ===")
 	fmt.Printf("Configuration saved to: %s\n", configPath)
@@ -188,9 +189,9 @@ func runQuickSetup(reader *bufio.Reader, fleexPath string) {
 		provider = "linode"
 	}
 
-	fmt.Print("Enter API token: ")
+	fmt.Print("Enter API token (or an env:, file: or cmd: reference): ")
 	token, _ := reader.ReadString('\n')
-	token = strings.TrimSpace(token)
+	token = secretValue(reader, provider+"-token", strings.TrimSpace(token))
 
 	config := models.Config{
 		Providers: map[string]models.Provider{
@@ -232,15 +233,16 @@ func runQuickSetup(reader *bufio.Reader, fleexPath string) {
 
 	createDefaultWorkflows(fleexPath)
 	createDefaultBuilds(fleexPath)
+	createDefaultUserData(fleexPath)
 
 	fmt.Println("\nSetup complete!")
 	fmt.Printf("Configuration: %s\n", configPath)
 }
 
 func configureProvider(reader *bufio.Reader, provider string) models.Provider {
-	fmt.Print("API Token: ")
+	fmt.Print("API Token (or an env:, file: or cmd: reference): ")
 	token, _ := reader.ReadString('\n')
-	token = strings.TrimSpace(token)
+	token = secretValue(reader, provider+"-token", strings.TrimSpace(token))
 
 	defaults := getDefaultProviderConfig(provider, token)
 
@@ -278,12 +280,39 @@ func configureProvider(reader *bufio.Reader, provider string) models.Provider {
 	password, _ := reader.ReadString('\n')
 	password = strings.TrimSpace(password)
 	if password != "" {
-		defaults.Password = password
+		defaults.Password = secretValue(reader, provider+"-password", password)
 	}
 
 	return defaults
 }
 
+// secretValue offers to keep a secret entered during setup in the keystore
+// and returns what config.json should hold
+func secretValue(reader *bufio.Reader, name, value string) string {
+	if value == "" || utils.IsSecretRef(value) {
+		return value
+	}
+
+	fmt.Print("Store it in the encrypted keystore instead of config.json? [Y/n]: ")
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "" && answer != "y" && answer != "yes" {
+		return value
+	}
+
+	keystore, err := utils.UnlockKeystore()
+	if err != nil {
+		utils.Log.Error("Keeping the secret in config.json: ", err)
+		return value
+	}
+	keystore.Set(name, value)
+	if err := keystore.Save(); err != nil {
+		utils.Log.Error("Keeping the secret in config.json: ", err)
+		return value
+	}
+	return utils.SecretKeystore + name
+}
+
 func configureCustomVMs(reader *bufio.Reader) []models.CustomVM {
 	var vms []models.CustomVM
 
@@ -499,6 +528,15 @@ steps:
 	}
 }
 
+func createDefaultUserData(fleexPath string) {
+	userDataPath := filepath.Join(fleexPath, "userdata")
+	if err := os.MkdirAll(userDataPath, 0755); err != nil {
+		return
+	}
+
+	os.WriteFile(filepath.Join(userDataPath, "hardened.yaml"), []byte(utils.HardenedUserData), 0644)
+}
+
 func createDefaultWorkflows(fleexPath string) {
 	workflowsPath := filepath.Join(fleexPath, "workflows")
 	if err := os.MkdirAll(workflowsPath, 0755); err != nil {
@@ -529,6 +567,7 @@ setup:
 steps:
   - name: httpx
     command: "httpx -l {INPUT} -silent -o {OUTPUT}"
+    collect: true
 
   - name: nuclei
     command: "nuclei -l {INPUT} -severity medium,high,critical -o {OUTPUT}"
@@ -559,9 +598,14 @@ output:
 description: Fast port scanning with masscan
 author: fleex
 
-vars:
-  PORTS: "1-65535"
-  RATE: "10000"
+params:
+  - name: PORTS
+    default: "1-65535"
+    description: Port range to scan
+  - name: RATE
+    type: int
+    default: "10000"
+    description: Packets per second
 
 steps:
   - name: masscan
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/notify"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Manage notifications",
+	Long: `Notifications are configured in the "notifications" section of config.json:
+
+  "notifications": [
+    {"type": "slack", "url": "https://hooks.slack.com/services/...", "events": ["scan.complete"]},
+    {"type": "discord", "url": "https://discord.com/api/webhooks/..."},
+    {"type": "webhook", "url": "http://127.0.0.1:8000/fleex"}
+  ]
+
+Events: spawn.complete, build.failed, box.failed, scan.complete.
+An empty events list subscribes to all of them.`,
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a test event to the configured notifications",
+	Long: `Send a test event to every configured notification, or to a single URL.
+
+Examples:
+  fleex notify test
+  fleex notify test --type slack --url http://127.0.0.1:8000`,
+	Run: func(cmd *cobra.Command, args []string) {
+		proxy, _ := rootCmd.PersistentFlags().GetString("proxy")
+		utils.SetProxy(proxy)
+
+		typeFlag, _ := cmd.Flags().GetString("type")
+		urlFlag, _ := cmd.Flags().GetString("url")
+
+		notifications := globalConfig.Notifications
+		if urlFlag != "" {
+			notifications = []models.Notification{{Type: typeFlag, URL: urlFlag}}
+		}
+
+		if len(notifications) == 0 {
+			utils.Log.Fatal("No notifications configured. Add a \"notifications\" section to config.json or use --url")
+		}
+
+		event := notify.Event{
+			Type:    notify.EventTest,
+			Title:   "Fleex test notification",
+			Message: "Notifications are working.",
+			Fields: map[string]string{
+				"provider": globalConfig.Settings.Provider,
+			},
+		}
+
+		failed := false
+		for _, n := range notifications {
+			if err := notify.SendTo(n, event); err != nil {
+				fmt.Printf("%-8s %s: %v\n", n.Type, n.URL, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%-8s %s: ok\n", n.Type, n.URL)
+		}
+
+		if failed {
+			utils.Log.Fatal("Some notifications could not be delivered")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(notifyCmd)
+	notifyCmd.AddCommand(notifyTestCmd)
+
+	notifyTestCmd.Flags().StringP("type", "t", models.NotificationWebhook, "Payload format used with --url (webhook, slack, discord)")
+	notifyTestCmd.Flags().StringP("url", "u", "", "Send to this URL instead of the configured notifications")
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"github.com/FleexSecurity/fleex/pkg/controller"
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/ui"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes needed to reconcile a fleet with its manifest",
+	Long: `Compare a fleet with the fleet.yaml describing it and show what
+'fleex apply' would change: boxes to spawn, extra boxes to delete, boxes in
+the wrong region or size to replace, and boxes failing the verification of
+the build recipe to rebuild.
+
+Examples:
+  fleex plan -f fleet.yaml
+  fleex plan -f fleet.yaml --skip-verify`,
+	Run: func(cmd *cobra.Command, args []string) {
+		proxy, _ := rootCmd.PersistentFlags().GetString("proxy")
+		utils.SetProxy(proxy)
+
+		file, _ := cmd.Flags().GetString("file")
+		skipVerify, _ := cmd.Flags().GetBool("skip-verify")
+
+		_, plan := loadFleetPlan(file, skipVerify)
+		showFleetPlan(plan)
+	},
+}
+
+// loadFleetPlan reads a manifest and computes the plan of its fleet
+func loadFleetPlan(file string, skipVerify bool) (controller.Controller, *models.FleetPlan) {
+	if file == "" {
+		utils.Log.Fatal("--file flag is required")
+	}
+
+	manifest, err := utils.ReadFleetManifest(file)
+	if err != nil {
+		utils.Log.Fatal(err)
+	}
+	if _, ok := globalConfig.Providers[manifest.Provider]; !ok {
+		utils.Log.Fatalf("Provider %s is not configured", manifest.Provider)
+	}
+
+	newController := controller.NewController(globalConfig)
+	plan, err := newController.PlanFleet(manifest, !skipVerify)
+	if err != nil {
+		utils.Log.Fatal(err)
+	}
+	return newController, plan
+}
+
+func showFleetPlan(plan *models.FleetPlan) {
+	actions := make([]ui.PlanAction, len(plan.Actions))
+	for i, a := range plan.Actions {
+		actions[i] = ui.PlanAction{Type: a.Type, Box: a.Box, Region: a.Region, Count: a.Count, Reason: a.Reason}
+	}
+	ui.ShowFleetPlan(plan.Manifest.Name, plan.Current, plan.Manifest.Count, actions)
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	planCmd.Flags().StringP("file", "f", "", "Fleet manifest file")
+	planCmd.Flags().BoolP("skip-verify", "", false, "Don't check boxes against the verification of the build recipe")
+}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Browse stored scan results",
+	Long: `Browse the scan and workflow runs stored in ~/.config/fleex/results.
+
+Examples:
+  fleex results ls --scope client-x
+  fleex results show <run>
+  fleex results export <run> -f json -o run.json
+  fleex results rm <run>`,
+}
+
+var resultsListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List stored runs",
+	Run: func(cmd *cobra.Command, args []string) {
+		workflowFlag, _ := cmd.Flags().GetString("workflow")
+		scopeFlag, _ := cmd.Flags().GetString("scope")
+		limitFlag, _ := cmd.Flags().GetInt("limit")
+
+		records, err := utils.ListRunRecords()
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		var filtered []models.RunRecord
+		for _, r := range records {
+			if workflowFlag != "" && r.Workflow != workflowFlag {
+				continue
+			}
+			if scopeFlag != "" && r.Scope != scopeFlag {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+
+		if len(filtered) == 0 {
+			fmt.Println("No stored runs found.")
+			return
+		}
+
+		if limitFlag > 0 && len(filtered) > limitFlag {
+			filtered = filtered[len(filtered)-limitFlag:]
+		}
+
+		fmt.Printf("%-40s %-20s %-20s %-20s %-10s %-8s %s\n", "ID", "WORKFLOW", "SCOPE", "STARTED", "DURATION", "BOXES", "LINES")
+		for i := len(filtered) - 1; i >= 0; i-- {
+			r := filtered[i]
+			fmt.Printf("%-40s %-20s %-20s %-20s %-10s %-8s %d\n",
+				r.ID, r.Workflow, r.Scope, r.Started.Format("2006-01-02 15:04:05"),
+				r.Finished.Sub(r.Started).Round(time.Second), boxesSummary(r), r.Lines)
+		}
+	},
+}
+
+var resultsShowCmd = &cobra.Command{
+	Use:   "show [run]",
+	Short: "Show the details of a stored run",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := utils.ReadRunRecord(args[0])
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		fmt.Printf("\n=== %s ===\n\n", r.ID)
+		fmt.Printf("Kind:      %s\n", r.Kind)
+		fmt.Printf("Workflow:  %s\n", r.Workflow)
+		fmt.Printf("Scope:     %s\n", r.Scope)
+		if r.Command != "" {
+			fmt.Printf("Command:   %s\n", r.Command)
+		}
+		fmt.Printf("Input:     %s\n", r.Input)
+		fmt.Printf("Output:    %s (%d lines)\n", r.Output, r.Lines)
+		fmt.Printf("Provider:  %s\n", r.Provider)
+		fmt.Printf("Started:   %s\n", r.Started.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Duration:  %s\n", r.Finished.Sub(r.Started).Round(time.Second))
+
+		if len(r.Vars) > 0 {
+			fmt.Println("\nVariables:")
+			for k, v := range r.Vars {
+				fmt.Printf("  %s: %s\n", k, v)
+			}
+		}
+
+		fmt.Printf("\nFleet %s (%d boxes):\n", r.Fleet.Name, len(r.Fleet.Boxes))
+		for _, box := range r.Fleet.Boxes {
+			fmt.Printf("  %-30s %s\n", box.Label, box.IP)
+		}
+
+		if len(r.Results) > 0 {
+			fmt.Println("\nResults:")
+			for _, result := range r.Results {
+				status := "ok"
+				if !result.Success {
+					status = "failed"
+				}
+				fmt.Printf("  %-30s %s\n", result.Box, status)
+				for _, step := range result.Steps {
+					fmt.Printf("     %-30s %-8v %s\n", step.Name, step.Success, step.Duration.Round(time.Millisecond))
+				}
+				if result.Error != "" {
+					fmt.Printf("     error: %s\n", result.Error)
+				}
+			}
+		}
+		fmt.Println()
+	},
+}
+
+var resultsExportCmd = &cobra.Command{
+	Use:   "export [run]",
+	Short: "Export the output of a stored run",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		formatFlag, _ := cmd.Flags().GetString("format")
+		outputFlag, _ := cmd.Flags().GetString("output")
+
+		r, err := utils.ReadRunRecord(args[0])
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+		outputPath, err := utils.RunOutputPath(r.ID)
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		output, err := os.ReadFile(outputPath)
+		if err != nil && !os.IsNotExist(err) {
+			utils.Log.Fatal(err)
+		}
+
+		var data []byte
+		switch formatFlag {
+		case "txt":
+			data = output
+		case "json":
+			export := struct {
+				*models.RunRecord
+				Lines []string `json:"output_lines"`
+			}{RunRecord: r}
+			for _, line := range strings.Split(string(output), "\n") {
+				if line != "" {
+					export.Lines = append(export.Lines, line)
+				}
+			}
+			data, err = json.MarshalIndent(export, "", "  ")
+			if err != nil {
+				utils.Log.Fatal(err)
+			}
+			data = append(data, '\n')
+		default:
+			utils.Log.Fatal("Unknown format: ", formatFlag, " (supported: txt, json)")
+		}
+
+		if outputFlag == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(outputFlag, data, 0644); err != nil {
+			utils.Log.Fatal(err)
+		}
+		fmt.Printf("Run %s exported to %s\n", r.ID, outputFlag)
+	},
+}
+
+var resultsRemoveCmd = &cobra.Command{
+	Use:   "rm [run...]",
+	Short: "Remove stored runs",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, id := range args {
+			if err := utils.DeleteRunRecord(id); err != nil {
+				utils.Log.Fatal(err)
+			}
+			fmt.Printf("Run %s removed\n", id)
+		}
+	},
+}
+
+var resultsDiffCmd = &cobra.Command{
+	Use:   "diff [old-run] [new-run]",
+	Short: "Compare the outputs of two stored runs",
+	Long: `Compare the outputs of two stored runs.
+
+Examples:
+  fleex results diff 20240101-020000-full-recon 20240102-020000-full-recon
+  fleex results diff <old-run> <new-run> --key host -o diff.txt`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		keyFlag, _ := cmd.Flags().GetString("key")
+		outputFlag, _ := cmd.Flags().GetString("output")
+
+		var outputs []string
+		for _, id := range args {
+			if _, err := utils.ReadRunRecord(id); err != nil {
+				utils.Log.Fatal(err)
+			}
+			output, err := utils.RunOutputPath(id)
+			if err != nil {
+				utils.Log.Fatal(err)
+			}
+			outputs = append(outputs, output)
+		}
+
+		diff, err := utils.DiffFiles(outputs[0], outputs[1], keyFlag)
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		if outputFlag != "" {
+			if err := utils.WriteDiff(diff, outputFlag); err != nil {
+				utils.Log.Fatal(err)
+			}
+			fmt.Printf("%d new, %d removed, %d unchanged. Written to %s\n", len(diff.New), len(diff.Removed), len(diff.Unchanged), utils.DiffOutputPath(outputFlag, "*"))
+			return
+		}
+
+		for _, line := range diff.New {
+			fmt.Println("+ " + line)
+		}
+		for _, line := range diff.Removed {
+			fmt.Println("- " + line)
+		}
+		fmt.Printf("\n%d new, %d removed, %d unchanged\n", len(diff.New), len(diff.Removed), len(diff.Unchanged))
+	},
+}
+
+func boxesSummary(r models.RunRecord) string {
+	if len(r.Results) == 0 {
+		return fmt.Sprintf("%d", len(r.Fleet.Boxes))
+	}
+	successful := 0
+	for _, result := range r.Results {
+		if result.Success {
+			successful++
+		}
+	}
+	return fmt.Sprintf("%d/%d", successful, len(r.Results))
+}
+
+func init() {
+	rootCmd.AddCommand(resultsCmd)
+	resultsCmd.AddCommand(resultsListCmd)
+	resultsCmd.AddCommand(resultsShowCmd)
+	resultsCmd.AddCommand(resultsExportCmd)
+	resultsCmd.AddCommand(resultsRemoveCmd)
+	resultsCmd.AddCommand(resultsDiffCmd)
+
+	resultsListCmd.Flags().StringP("workflow", "w", "", "Only show runs of this workflow")
+	resultsListCmd.Flags().StringP("scope", "s", "", "Only show runs on this scope")
+	resultsListCmd.Flags().IntP("limit", "", 0, "Only show the latest N runs")
+
+	resultsExportCmd.Flags().StringP("format", "f", "txt", "Export format (txt, json)")
+	resultsExportCmd.Flags().StringP("output", "o", "", "Output file (default: stdout)")
+
+	resultsDiffCmd.Flags().StringP("key", "k", "", "JSON field used to compare lines (default: whole line)")
+	resultsDiffCmd.Flags().StringP("output", "o", "", "Write the new, removed and unchanged sets next to this file")
+}
@@ -54,6 +54,7 @@ func init() {
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.PersistentFlags().StringP("loglevel", "l", "info", "Set log level. Available: debug, info, warn, error, fatal")
 	rootCmd.PersistentFlags().StringP("proxy", "", "", "HTTP Proxy (Useful for debugging. Example: http://127.0.0.1:8080)")
+	rootCmd.PersistentFlags().BoolP("legacy-fleet-match", "", false, "Also select boxes whose label starts with the fleet name (untagged fleets)")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -86,8 +87,19 @@ func initConfig() {
 		utils.Log.Fatal(err)
 	}
 
+	// secret and init manage the keystore themselves
+	if !skipSecrets() {
+		if err := utils.ResolveConfigSecrets(&config); err != nil {
+			utils.Log.Fatal("Failed to resolve secrets: ", err)
+		}
+	}
+
 	globalConfig = &config
 
+	if legacy, _ := rootCmd.PersistentFlags().GetBool("legacy-fleet-match"); legacy {
+		globalConfig.Settings.LegacyFleetMatch = true
+	}
+
 	levelString, _ := rootCmd.PersistentFlags().GetString("loglevel")
 	utils.SetLogLevel(levelString)
 }
@@ -1,15 +1,18 @@
 package cmd
 
 import (
+	"sort"
+
 	"github.com/FleexSecurity/fleex/pkg/controller"
 	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/ui"
 	"github.com/FleexSecurity/fleex/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
 var runCmd = &cobra.Command{
 	Use:   "run",
-	Short: "Send a command to a fleet",
+	Short: "Run a command on every box of a fleet in parallel",
 	Run: func(cmd *cobra.Command, args []string) {
 		proxy, _ := rootCmd.PersistentFlags().GetString("proxy")
 		utils.SetProxy(proxy)
@@ -19,6 +22,8 @@ var runCmd = &cobra.Command{
 		commandFlag, _ := cmd.Flags().GetString("command")
 		portFlag, _ := cmd.Flags().GetInt("port")
 		usernameFlag, _ := cmd.Flags().GetString("username")
+		parallelFlag, _ := cmd.Flags().GetInt("parallel")
+		outputDirFlag, _ := cmd.Flags().GetString("output-dir")
 
 		if providerFlag != "" {
 			globalConfig.Settings.Provider = providerFlag
@@ -29,25 +34,47 @@ var runCmd = &cobra.Command{
 		if vmInfo == nil {
 			utils.Log.Fatal("Provider or custom VM not found")
 		}
-
-		if portFlag != -1 {
-			vmInfo.Port = portFlag
-		}
-		if usernameFlag != "" {
-			vmInfo.Username = usernameFlag
+		if portFlag == -1 {
+			portFlag = 0
 		}
 
 		newController := controller.NewController(globalConfig)
 
-		fleets := newController.GetFleet(fleetName)
-
-		if len(fleets) == 0 {
-			utils.Log.Fatal("Fleet not found")
+		opts := models.RunOptions{
+			FleetName: fleetName,
+			Command:   commandFlag,
+			Parallel:  parallelFlag,
+			OutputDir: outputDirFlag,
+			Port:      portFlag,
+			Username:  usernameFlag,
+			KeyPath:   globalConfig.SSHKeys.PrivateFile,
+		}
+		results, err := newController.RunCommand(opts)
+		if err != nil {
+			utils.Log.Fatal(err)
 		}
 
-		newController.RunCommand(fleetName, commandFlag)
+		summary := make([]ui.RunResult, 0, len(results))
+		failed := 0
+		for _, r := range results {
+			item := ui.RunResult{BoxName: r.BoxName, ExitCode: r.ExitCode, Duration: r.Duration}
+			if r.Error != nil {
+				item.Error = r.Error.Error()
+			}
+			if r.Error != nil || r.ExitCode != 0 {
+				failed++
+			}
+			summary = append(summary, item)
+		}
+		sort.Slice(summary, func(i, j int) bool { return summary[i].BoxName < summary[j].BoxName })
+		ui.ShowRunSummary(summary)
 
-		utils.Log.Info("Command executed on fleet " + fleetName)
+		if outputDirFlag != "" {
+			utils.Log.Info("Output of each box saved in " + outputDirFlag)
+		}
+		if failed > 0 {
+			utils.Log.Fatalf("Command failed on %d/%d boxes", failed, len(results))
+		}
 	},
 }
 
@@ -59,6 +86,8 @@ func init() {
 	runCmd.Flags().IntP("port", "p", -1, "SSH port")
 	runCmd.Flags().StringP("username", "U", "", "SSH username")
 	runCmd.Flags().StringP("provider", "P", "", "Service provider")
+	runCmd.Flags().IntP("parallel", "", 10, "Number of boxes running the command at the same time (0 for all)")
+	runCmd.Flags().StringP("output-dir", "o", "", "Save the output of each box to <output-dir>/<box>.txt")
 
 	runCmd.MarkFlagRequired("command")
 	runCmd.MarkFlagRequired("name")
@@ -49,6 +49,12 @@ In workflow mode, each machine:
 
 		verticalFlag, _ := cmd.Flags().GetBool("vertical")
 		splitVarFlag, _ := cmd.Flags().GetString("split-var")
+		shardFlag, _ := cmd.Flags().GetString("shard")
+
+		autoscaleFlag, _ := cmd.Flags().GetString("autoscale")
+		if autoscaleFlag != "" && (verticalFlag || workflowName != "" || workflowFile != "") {
+			utils.Log.Fatal("--autoscale is only supported for horizontal command scans")
+		}
 
 		if workflowName != "" || workflowFile != "" {
 			runWorkflowMode(cmd, fleetNameFlag, inputFlag, output, chunksFolder, deleteFlag, workflowName, workflowFile)
@@ -66,12 +72,16 @@ In workflow mode, each machine:
 			}
 		}
 
-		for _, param := range paramsFlag {
-			splits := strings.SplitN(param, ":", 2)
-			if len(splits) == 2 {
-				key, value := splits[0], splits[1]
-				module.Vars[key] = value
-			}
+		utils.ApplyParamFlags(module.Vars, paramsFlag)
+
+		if shardFlag != "" {
+			module.Shard = shardFlag
+		}
+		if compressFlag, _ := cmd.Flags().GetBool("compress-chunks"); compressFlag {
+			module.CompressChunks = true
+		}
+		if err := utils.ValidateShard(module.Shard); err != nil {
+			utils.Log.Fatal(err)
 		}
 
 		if commandFlag != "" {
@@ -94,13 +104,51 @@ In workflow mode, each machine:
 			if _, ok := module.Vars[splitVarFlag]; !ok {
 				log.Fatalf("Variable '%s' not found in params. Use -p %s:/path/to/file", splitVarFlag, splitVarFlag)
 			}
-			newController.VerticalStart(fleetNameFlag, finalCommand, deleteFlag, output, chunksFolder, module, splitVarFlag)
+			newController.VerticalStart(fleetNameFlag, finalCommand, deleteFlag, output, chunksFolder, module, splitVarFlag, diffOptions(cmd), streamOptions(cmd))
+		} else if autoscaleFlag != "" {
+			scale := autoscaleOptions(cmd, autoscaleFlag, module)
+			newController.AutoscaleStart(fleetNameFlag, finalCommand, deleteFlag, inputFlag, output, chunksFolder, module, scale, diffOptions(cmd), streamOptions(cmd))
 		} else {
-			newController.Start(fleetNameFlag, finalCommand, deleteFlag, inputFlag, output, chunksFolder, module)
+			newController.Start(fleetNameFlag, finalCommand, deleteFlag, inputFlag, output, chunksFolder, module, diffOptions(cmd), streamOptions(cmd))
 		}
 	},
 }
 
+func autoscaleOptions(cmd *cobra.Command, value string, module *models.Module) models.AutoscaleOptions {
+	min, max, err := utils.ParseAutoscale(value)
+	if err != nil {
+		utils.Log.Fatal(err)
+	}
+	chunkSize, _ := cmd.Flags().GetInt("chunk-size")
+	budget, _ := cmd.Flags().GetFloat64("budget")
+	buildFlag, _ := cmd.Flags().GetString("build")
+
+	scale := models.AutoscaleOptions{
+		Min:       min,
+		Max:       max,
+		ChunkSize: chunkSize,
+		Budget:    budget,
+	}
+
+	if buildFlag == "" {
+		buildFlag = module.Build
+	}
+	if buildFlag != "" {
+		scale.Recipe, err = utils.ReadBuildFile(buildFlag)
+		if err != nil {
+			utils.Log.Fatal("Failed to load recipe: ", err)
+		}
+	}
+
+	provider := globalConfig.Settings.Provider
+	pricing := getProviderPricing(provider)
+	scale.HourlyCost = pricing.HourlyCost
+	if budget > 0 && pricing.Size != globalConfig.Providers[provider].Size {
+		utils.Log.Warnf("No price known for size %s, assuming $%.4f/hour (%s) for the budget", globalConfig.Providers[provider].Size, pricing.HourlyCost, pricing.Size)
+	}
+	return scale
+}
+
 func runWorkflowMode(cmd *cobra.Command, fleetName, input, output, chunksFolder string, deleteFleet bool, workflowName, workflowFile string) {
 	var workflow *models.Workflow
 	var err error
@@ -115,16 +163,30 @@ func runWorkflowMode(cmd *cobra.Command, fleetName, input, output, chunksFolder
 		utils.Log.Fatal("Failed to load workflow: ", err)
 	}
 
+	helpParams, _ := cmd.Flags().GetBool("help-params")
+	if helpParams {
+		fmt.Printf("\nParameters for workflow %s:\n\n", workflow.Name)
+		printParams(workflow.Params)
+		fmt.Println()
+		return
+	}
+
+	shardFlag, _ := cmd.Flags().GetString("shard")
+	if shardFlag != "" {
+		workflow.Shard = shardFlag
+	}
+	if compressFlag, _ := cmd.Flags().GetBool("compress-chunks"); compressFlag {
+		workflow.CompressChunks = true
+	}
+	if err := utils.ValidateShard(workflow.Shard); err != nil {
+		utils.Log.Fatal(err)
+	}
+
 	paramsFlag, _ := cmd.Flags().GetStringSlice("params")
 	if workflow.Vars == nil {
 		workflow.Vars = make(map[string]string)
 	}
-	for _, param := range paramsFlag {
-		splits := strings.SplitN(param, ":", 2)
-		if len(splits) == 2 {
-			workflow.Vars[splits[0]] = splits[1]
-		}
-	}
+	utils.ApplyParamFlags(workflow.Vars, paramsFlag)
 
 	newController := controller.NewController(globalConfig)
 
@@ -145,6 +207,33 @@ func runWorkflowMode(cmd *cobra.Command, fleetName, input, output, chunksFolder
 		Delete:       deleteFleet,
 		DryRun:       dryRun,
 		Verbose:      verbose,
+		Diff:         diffOptions(cmd),
+		Stream:       streamOptions(cmd),
+	}
+
+	if len(workflow.Matrix) > 0 {
+		runs, err := newController.RunWorkflowMatrix(opts)
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		if !dryRun {
+			fmt.Printf("\nMatrix complete: %d runs\n", len(runs))
+			for _, run := range runs {
+				successCount := 0
+				for _, r := range run.Results {
+					if r.Success {
+						successCount++
+					}
+				}
+				if run.Error != nil {
+					fmt.Printf("  %-40s failed: %v\n", run.Suffix, run.Error)
+				} else {
+					fmt.Printf("  %-40s %d/%d successful -> %s\n", run.Suffix, successCount, len(run.Results), run.Output)
+				}
+			}
+		}
+		return
 	}
 
 	results, err := newController.RunWorkflow(opts)
@@ -218,6 +307,12 @@ var scanShowCmd = &cobra.Command{
 		if workflow.SplitVar != "" {
 			fmt.Printf("Split var:   %s\n", workflow.SplitVar)
 		}
+		if workflow.Shard != "" {
+			fmt.Printf("Shard:       %s\n", workflow.Shard)
+		}
+		if len(workflow.Included) > 0 {
+			fmt.Printf("Included:    %s\n", strings.Join(workflow.Included, ", "))
+		}
 
 		if len(workflow.Vars) > 0 {
 			fmt.Println("\nVariables:")
@@ -230,6 +325,11 @@ var scanShowCmd = &cobra.Command{
 			}
 		}
 
+		if len(workflow.Params) > 0 {
+			fmt.Println("\nParameters:")
+			printParams(workflow.Params)
+		}
+
 		if len(workflow.Setup) > 0 {
 			fmt.Println("\nSetup (runs on all boxes first):")
 			for _, c := range workflow.Setup {
@@ -243,6 +343,9 @@ var scanShowCmd = &cobra.Command{
 			if step.Id != "" {
 				stepHeader += fmt.Sprintf(" [id: %s]", step.Id)
 			}
+			if step.From != "" {
+				stepHeader += fmt.Sprintf(" (from %s)", step.From)
+			}
 			fmt.Printf("  %s\n", stepHeader)
 
 			stepScaleMode := step.ScaleMode
@@ -266,6 +369,20 @@ var scanShowCmd = &cobra.Command{
 			if step.Timeout != "" {
 				fmt.Printf("     timeout: %s\n", step.Timeout)
 			}
+			if step.Collect {
+				fmt.Println("     collect: true")
+			}
+		}
+
+		if len(workflow.Matrix) > 0 {
+			mode := workflow.MatrixMode
+			if mode == "" {
+				mode = models.MatrixModeSequential
+			}
+			fmt.Printf("\nMatrix (%s):\n", mode)
+			for k, values := range workflow.Matrix {
+				fmt.Printf("  %s: %s\n", k, strings.Join(values, ", "))
+			}
 		}
 
 		if workflow.Output.Aggregate != "" || workflow.Output.Deduplicate {
@@ -281,6 +398,54 @@ var scanShowCmd = &cobra.Command{
 	},
 }
 
+func diffOptions(cmd *cobra.Command) models.DiffOptions {
+	diff, _ := cmd.Flags().GetBool("diff")
+	diffKey, _ := cmd.Flags().GetString("diff-key")
+	scope, _ := cmd.Flags().GetString("scope")
+
+	return models.DiffOptions{
+		Enabled: diff,
+		Key:     diffKey,
+		Scope:   scope,
+	}
+}
+
+func streamOptions(cmd *cobra.Command) models.StreamOptions {
+	stream, _ := cmd.Flags().GetBool("stream")
+	streamFile, _ := cmd.Flags().GetString("stream-file")
+	streamWebhook, _ := cmd.Flags().GetString("stream-webhook")
+	streamWebhookType, _ := cmd.Flags().GetString("stream-webhook-type")
+	streamMatch, _ := cmd.Flags().GetString("stream-match")
+
+	return models.StreamOptions{
+		Stdout:      stream,
+		File:        streamFile,
+		Webhook:     streamWebhook,
+		WebhookType: streamWebhookType,
+		Match:       streamMatch,
+	}
+}
+
+func printParams(params []models.Param) {
+	if len(params) == 0 {
+		fmt.Println("  (no parameters declared)")
+		return
+	}
+
+	fmt.Printf("  %-20s %-8s %-9s %-20s %s\n", "NAME", "TYPE", "REQUIRED", "DEFAULT", "DESCRIPTION")
+	for _, param := range params {
+		paramType := param.Type
+		if paramType == "" {
+			paramType = models.ParamTypeString
+		}
+		required := "no"
+		if param.Required {
+			required = "yes"
+		}
+		fmt.Printf("  %-20s %-8s %-9s %-20s %s\n", param.Name, paramType, required, param.Default, param.Description)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(scanCmd)
 
@@ -304,6 +469,25 @@ func init() {
 	scanCmd.Flags().StringP("workflow-file", "", "", "Custom workflow file path")
 	scanCmd.Flags().BoolP("dry-run", "", false, "Show what would be executed (workflow mode)")
 	scanCmd.Flags().BoolP("verbose", "v", false, "Show detailed output (workflow mode)")
+	scanCmd.Flags().BoolP("help-params", "", false, "List the parameters accepted by the workflow and exit")
+
+	scanCmd.Flags().BoolP("diff", "", false, "Compare the output with the previous run of the same workflow and scope")
+	scanCmd.Flags().StringP("diff-key", "", "", "JSON field used to compare output lines in diff mode (e.g. host)")
+	scanCmd.Flags().StringP("scope", "", "", "Scope name used to find the previous run (default: input file name)")
+
+	scanCmd.Flags().BoolP("stream", "", false, "Print new output lines from the boxes while the scan is running")
+	scanCmd.Flags().StringP("stream-file", "", "", "Append new output lines to this file while the scan is running")
+	scanCmd.Flags().StringP("stream-webhook", "", "", "Send new output lines to this webhook while the scan is running")
+	scanCmd.Flags().StringP("stream-webhook-type", "", models.NotificationWebhook, "Payload format of --stream-webhook (webhook, slack, discord)")
+	scanCmd.Flags().StringP("stream-match", "", "", "Only stream the lines matching this regular expression (e.g. \"critical|high\")")
+
+	scanCmd.Flags().BoolP("compress-chunks", "", false, "Gzip input chunks before upload (decompressed on the boxes)")
+	scanCmd.Flags().StringP("shard", "", "", "Input split strategy: contiguous, round-robin, apex, subnet24, weighted (default: contiguous)")
+
+	scanCmd.Flags().StringP("autoscale", "", "", "Grow the fleet between min:max boxes while the input queue is long (e.g. 2:20)")
+	scanCmd.Flags().IntP("chunk-size", "", 100, "Lines per queued chunk when autoscaling")
+	scanCmd.Flags().Float64P("budget", "", 0, "Estimated cost in USD above which autoscaling stops adding boxes")
+	scanCmd.Flags().StringP("build", "", "", "Build recipe run on boxes added by autoscaling")
 
 	scanCmd.Flags().BoolP("vertical", "", false, "Enable vertical scanning (split wordlist instead of targets)")
 	scanCmd.Flags().StringP("split-var", "", "", "Variable name to split in vertical mode (e.g., WORDLIST)")
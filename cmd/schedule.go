@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/controller"
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage recurring scans",
+	Long: `Manage recurring scans executed by 'fleex daemon'.
+
+Each run spawns a dedicated fleet, runs the workflow, deletes the fleet and
+keeps the output in the run history.
+
+Examples:
+  fleex schedule add nightly --cron "0 2 * * *" -w full-recon -i scope.txt -c 5
+  fleex schedule ls
+  fleex schedule history nightly
+  fleex schedule run nightly
+  fleex schedule rm nightly`,
+}
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add [job-name]",
+	Short: "Add or replace a scheduled job",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cronFlag, _ := cmd.Flags().GetString("cron")
+		workflowFlag, _ := cmd.Flags().GetString("workflow")
+		inputFlag, _ := cmd.Flags().GetString("input")
+		countFlag, _ := cmd.Flags().GetInt("count")
+		fleetFlag, _ := cmd.Flags().GetString("fleet")
+		providerFlag, _ := cmd.Flags().GetString("provider")
+		buildFlag, _ := cmd.Flags().GetString("build")
+		retentionFlag, _ := cmd.Flags().GetInt("retention")
+		paramsFlag, _ := cmd.Flags().GetStringSlice("params")
+		diffFlag, _ := cmd.Flags().GetBool("diff")
+		diffKeyFlag, _ := cmd.Flags().GetString("diff-key")
+
+		if err := utils.ValidateScheduleName(args[0]); err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		if fleetFlag != "" {
+			if err := utils.ValidateFleetName(fleetFlag); err != nil {
+				utils.Log.Fatal(err)
+			}
+		}
+
+		if _, err := cron.ParseStandard(cronFlag); err != nil {
+			utils.Log.Fatal("Invalid cron expression: ", err)
+		}
+
+		if _, err := utils.ReadWorkflowFile(workflowFlag); err != nil {
+			utils.Log.Fatal("Failed to load workflow: ", err)
+		}
+
+		if !utils.FileExists(utils.ExpandPath(inputFlag)) {
+			utils.Log.Fatal("Input file not found: ", inputFlag)
+		}
+
+		if countFlag < 1 {
+			utils.Log.Fatal("--count must be at least 1")
+		}
+
+		if providerFlag != "" && controller.GetProvider(providerFlag) == -1 {
+			utils.Log.Fatal(models.ErrInvalidProvider)
+		}
+
+		job := &models.ScheduledJob{
+			Name:      args[0],
+			Cron:      cronFlag,
+			Workflow:  workflowFlag,
+			Input:     inputFlag,
+			FleetName: fleetFlag,
+			FleetSize: countFlag,
+			Provider:  providerFlag,
+			Build:     buildFlag,
+			Retention: retentionFlag,
+			Params:    make(map[string]string),
+			Diff:      diffFlag,
+			DiffKey:   diffKeyFlag,
+		}
+		utils.ApplyParamFlags(job.Params, paramsFlag)
+
+		if err := utils.SaveSchedule(job); err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		fmt.Printf("Scheduled job '%s' saved (fleet: %s)\n", job.Name, controller.ScheduleFleetName(*job))
+	},
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List scheduled jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		jobs, err := utils.ListSchedules()
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		if len(jobs) == 0 {
+			fmt.Println("No scheduled jobs found. Use 'fleex schedule add' to create one.")
+			return
+		}
+
+		fmt.Printf("%-20s %-16s %-20s %-6s %-12s %-20s\n", "NAME", "CRON", "WORKFLOW", "BOXES", "PROVIDER", "NEXT RUN")
+		for _, name := range jobs {
+			job, err := utils.ReadScheduleFile(name)
+			if err != nil {
+				continue
+			}
+
+			next := "-"
+			if schedule, err := cron.ParseStandard(job.Cron); err == nil {
+				next = schedule.Next(time.Now()).Format("2006-01-02 15:04")
+			}
+
+			provider := job.Provider
+			if provider == "" {
+				provider = "(default)"
+			}
+			fmt.Printf("%-20s %-16s %-20s %-6d %-12s %-20s\n", job.Name, job.Cron, job.Workflow, job.FleetSize, provider, next)
+		}
+	},
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "rm [job-name]",
+	Short: "Remove a scheduled job",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := utils.DeleteSchedule(args[0]); err != nil {
+			utils.Log.Fatal(err)
+		}
+		fmt.Printf("Scheduled job '%s' removed\n", args[0])
+	},
+}
+
+var scheduleHistoryCmd = &cobra.Command{
+	Use:   "history [job-name]",
+	Short: "Show the run history of a scheduled job",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		history, err := utils.ReadScheduleHistory(args[0])
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		if len(history) == 0 {
+			fmt.Printf("No runs recorded for '%s'\n", args[0])
+			return
+		}
+
+		fmt.Printf("%-20s %-10s %-10s %-8s %s\n", "STARTED", "DURATION", "STATUS", "BOXES", "OUTPUT / ERROR")
+		for _, run := range history {
+			status := "success"
+			detail := run.Output
+			if run.Skipped {
+				status = "skipped"
+				detail = run.Error
+			} else if !run.Success {
+				status = "failed"
+				detail = run.Error
+			}
+			duration := run.Finished.Sub(run.Started).Round(time.Second)
+			fmt.Printf("%-20s %-10s %-10s %-8s %s\n", run.Started.Format("2006-01-02 15:04:05"), duration, status, fmt.Sprintf("%d/%d", run.Successful, run.Boxes), detail)
+		}
+	},
+}
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run [job-name]",
+	Short: "Run a scheduled job now",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		proxy, _ := rootCmd.PersistentFlags().GetString("proxy")
+		utils.SetProxy(proxy)
+
+		job, err := utils.ReadScheduleFile(args[0])
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		run := runScheduledJob(*job)
+		if !run.Success {
+			utils.Log.Fatal("Run failed: ", run.Error)
+		}
+		fmt.Printf("Run complete: %d/%d successful. Output: %s\n", run.Successful, run.Boxes, run.Output)
+	},
+}
+
+// runScheduledJob runs a job with a controller bound to the job provider
+func runScheduledJob(job models.ScheduledJob) models.ScheduleRun {
+	config := *globalConfig
+	if job.Provider != "" {
+		config.Settings.Provider = job.Provider
+	}
+
+	newController := controller.NewController(&config)
+	return newController.RunScheduledJob(job)
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+
+	scheduleCmd.AddCommand(scheduleAddCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleRemoveCmd)
+	scheduleCmd.AddCommand(scheduleHistoryCmd)
+	scheduleCmd.AddCommand(scheduleRunCmd)
+
+	scheduleAddCmd.Flags().StringP("cron", "", "", "Cron expression (e.g. \"0 2 * * *\")")
+	scheduleAddCmd.Flags().StringP("workflow", "w", "", "Workflow name or file")
+	scheduleAddCmd.Flags().StringP("input", "i", "", "Input file")
+	scheduleAddCmd.Flags().IntP("count", "c", 2, "Fleet size")
+	scheduleAddCmd.Flags().StringP("fleet", "n", "", "Fleet name (default: sched-<job-name>)")
+	scheduleAddCmd.Flags().StringP("provider", "p", "", "Service provider (Supported: linode, digitalocean, vultr)")
+	scheduleAddCmd.Flags().StringP("build", "b", "", "Build recipe to run after spawn")
+	scheduleAddCmd.Flags().IntP("retention", "r", 10, "Number of runs to keep in the history (0 keeps all)")
+	scheduleAddCmd.Flags().StringSliceP("params", "", []string{}, "Set workflow parameters in the format KEY:VALUE")
+	scheduleAddCmd.Flags().BoolP("diff", "", false, "Compare each run with the previous one")
+	scheduleAddCmd.Flags().StringP("diff-key", "", "", "JSON field used to compare output lines in diff mode")
+
+	scheduleAddCmd.MarkFlagRequired("cron")
+	scheduleAddCmd.MarkFlagRequired("workflow")
+	scheduleAddCmd.MarkFlagRequired("input")
+}
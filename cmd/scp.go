@@ -1,12 +1,12 @@
 package cmd
 
 import (
-	"log"
 	"path/filepath"
 	"strings"
 
 	"github.com/FleexSecurity/fleex/pkg/controller"
 	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/provider"
 	"github.com/FleexSecurity/fleex/pkg/utils"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -15,17 +15,18 @@ import (
 // scpCmd represents the scp command
 var scpCmd = &cobra.Command{
 	Use:   "scp",
-	Short: "Send a file/folder to a fleet using SCP",
+	Short: "Copy files/folders to or from a fleet using SCP",
 	Run: func(cmd *cobra.Command, args []string) {
 		proxy, _ := rootCmd.PersistentFlags().GetString("proxy")
 		utils.SetProxy(proxy)
 
 		providerFlag, _ := cmd.Flags().GetString("provider")
 		usernameFlag, _ := cmd.Flags().GetString("username")
-		sourceFlag, _ := cmd.Flags().GetString("source")
+		sourceFlag, _ := cmd.Flags().GetStringSlice("source")
 		portFlag, _ := cmd.Flags().GetInt("port")
 		destinationFlag, _ := cmd.Flags().GetString("destination")
 		nameFlag, _ := cmd.Flags().GetString("name")
+		fromFleetFlag, _ := cmd.Flags().GetBool("from-fleet")
 
 		home, _ := homedir.Dir()
 
@@ -46,7 +47,7 @@ var scpCmd = &cobra.Command{
 			vmInfo.Username = usernameFlag
 		}
 
-		if strings.HasPrefix(destinationFlag, home) {
+		if !fromFleetFlag && strings.HasPrefix(destinationFlag, home) {
 			if home != "/root" {
 				destinationFlag = filepath.Join("/home", vmInfo.Username, strings.TrimPrefix(destinationFlag, home))
 			}
@@ -58,26 +59,33 @@ var scpCmd = &cobra.Command{
 		if len(fleets) == 0 {
 			utils.Log.Fatal("Box not found")
 		}
+
+		// An exact box name targets that box only, otherwise the whole fleet
+		boxes := fleets
 		for _, box := range fleets {
 			if box.Label == nameFlag {
-				err := controller.SendSCP(sourceFlag, destinationFlag, box.IP, vmInfo.Username, vmInfo.Port, vmInfo.KeyPath)
-				if err != nil {
-					log.Fatal(err)
-				}
-				return
+				boxes = []provider.Box{box}
+				break
 			}
 		}
 
-		for _, box := range fleets {
-			if utils.MatchesFleetName(box.Label, nameFlag) {
-				err := controller.SendSCP(sourceFlag, destinationFlag, box.IP, vmInfo.Username, vmInfo.Port, vmInfo.KeyPath)
-				if err != nil {
-					log.Fatal(err)
-				}
-			}
+		opts := models.SCPOptions{
+			Sources:     sourceFlag,
+			Destination: destinationFlag,
+			FromFleet:   fromFleetFlag,
+			Port:        vmInfo.Port,
+			Username:    vmInfo.Username,
+			KeyPath:     vmInfo.KeyPath,
+		}
+		if err := newController.SCP(boxes, opts); err != nil {
+			utils.Log.Fatal(err)
 		}
 
-		utils.Log.Info("SCP completed, you can find your files in " + destinationFlag)
+		if fromFleetFlag {
+			utils.Log.Info("SCP completed, you can find your files in " + filepath.Join(destinationFlag, "<box>"))
+		} else {
+			utils.Log.Info("SCP completed, you can find your files in " + destinationFlag)
+		}
 	},
 }
 
@@ -88,8 +96,9 @@ func init() {
 	scpCmd.Flags().StringP("name", "n", "pwn", "Fleet name")
 	scpCmd.Flags().StringP("username", "U", "", "Username")
 	scpCmd.Flags().IntP("port", "", -1, "SSH port")
-	scpCmd.Flags().StringP("source", "s", "", "Source file / folder")
-	scpCmd.Flags().StringP("destination", "d", "", "Destination file / folder")
+	scpCmd.Flags().StringSliceP("source", "s", nil, "Source files / folders, globs allowed (remote paths with --from-fleet)")
+	scpCmd.Flags().StringP("destination", "d", "", "Destination file / folder (local folder with --from-fleet)")
+	scpCmd.Flags().BoolP("from-fleet", "", false, "Pull the sources from every box into <destination>/<box>")
 
 	scpCmd.MarkFlagRequired("source")
 	scpCmd.MarkFlagRequired("destination")
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/utils"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage the encrypted keystore",
+	Long: `Secrets such as provider tokens can be kept out of config.json with a reference
+resolved when fleex starts:
+
+  "token": "env:LINODE_TOKEN"              # environment variable
+  "token": "file:/run/secrets/linode"      # file content
+  "token": "cmd:pass show fleex/linode"    # command output
+  "token": "keystore:linode-token"         # encrypted keystore
+
+The keystore is unlocked with a passphrase, read from FLEEX_KEYSTORE_PASSPHRASE
+or asked on the terminal.
+
+Examples:
+  fleex secret set linode-token
+  echo "$TOKEN" | fleex secret set linode-token
+  fleex secret list
+  fleex secret rm linode-token`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set [name]",
+	Short: "Store a secret in the keystore",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keystore, err := utils.UnlockKeystore()
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+
+		value, err := readSecretValue(args[0])
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+		if value == "" {
+			utils.Log.Fatal("Empty secret")
+		}
+
+		keystore.Set(args[0], value)
+		if err := keystore.Save(); err != nil {
+			utils.Log.Fatal(err)
+		}
+		fmt.Printf("Secret '%s' stored, reference it as \"%s%s\"\n", args[0], utils.SecretKeystore, args[0])
+	},
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the secrets of the keystore",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !utils.KeystoreExists() {
+			fmt.Println("No keystore. Add a secret with 'fleex secret set <name>'.")
+			return
+		}
+		keystore, err := utils.UnlockKeystore()
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+		for _, name := range keystore.Names() {
+			fmt.Println(name)
+		}
+	},
+}
+
+var secretRmCmd = &cobra.Command{
+	Use:   "rm [name]",
+	Short: "Remove a secret from the keystore",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !utils.KeystoreExists() {
+			utils.Log.Fatal("No keystore")
+		}
+		keystore, err := utils.UnlockKeystore()
+		if err != nil {
+			utils.Log.Fatal(err)
+		}
+		if !keystore.Delete(args[0]) {
+			utils.Log.Fatalf("Secret '%s' not found", args[0])
+		}
+		if err := keystore.Save(); err != nil {
+			utils.Log.Fatal(err)
+		}
+		fmt.Printf("Secret '%s' removed\n", args[0])
+	},
+}
+
+// readSecretValue asks for a secret without echoing it, or reads it from
+// stdin when it is not a terminal
+func readSecretValue(name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && value == "" {
+			return "", err
+		}
+		return strings.TrimSpace(value), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+	value, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return strings.TrimSpace(string(value)), err
+}
+
+// skipSecrets reports whether the command being run manages secrets itself,
+// so the config references must not be resolved first
+func skipSecrets() bool {
+	cmd, _, err := rootCmd.Find(os.Args[1:])
+	if err != nil {
+		return false
+	}
+	return cmd == initCmd || cmd == secretCmd || cmd.Parent() == secretCmd
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretListCmd)
+	secretCmd.AddCommand(secretRmCmd)
+}
@@ -37,6 +37,10 @@ var spawnCmd = &cobra.Command{
 			utils.Log.Fatal(models.ErrInvalidProvider)
 		}
 
+		if err := utils.ValidateFleetName(fleetName); err != nil {
+			utils.Log.Fatal(err)
+		}
+
 		providerInfo := globalConfig.Providers[providerFlag]
 		if regionFlag != "" {
 			providerInfo.Region = regionFlag
@@ -49,15 +53,29 @@ var spawnCmd = &cobra.Command{
 		}
 		globalConfig.Providers[providerFlag] = providerInfo
 
-		newController := controller.NewController(globalConfig)
-		newController.SpawnFleet(fleetName, fleetCount, skipWait, false)
-
+		var recipe *models.BuildRecipe
 		if buildRecipe != "" {
-			recipe, err := utils.ReadBuildFile(buildRecipe)
+			var err error
+			recipe, err = utils.ReadBuildFile(buildRecipe)
 			if err != nil {
 				utils.Log.Fatal("Failed to load build recipe: ", err)
 			}
 
+			paramsFlag, _ := cmd.Flags().GetStringSlice("params")
+			if recipe.Vars == nil {
+				recipe.Vars = make(map[string]string)
+			}
+			utils.ApplyParamFlags(recipe.Vars, paramsFlag)
+			if err := utils.ApplyParams(recipe.Params, recipe.Vars); err != nil {
+				utils.Log.Fatal("Invalid recipe params: ", err)
+			}
+		}
+
+		newController := controller.NewController(globalConfig)
+		newController.SpawnFleet(fleetName, fleetCount, skipWait, false)
+
+		if recipe != nil {
+
 			fmt.Printf("Building fleet '%s' with recipe '%s'...\n", fleetName, recipe.Name)
 
 			opts := models.BuildOptions{
@@ -100,4 +118,5 @@ func init() {
 	spawnCmd.Flags().StringP("image", "I", "", "Image")
 	spawnCmd.Flags().StringP("build", "b", "", "Build recipe to run after spawn")
 	spawnCmd.Flags().BoolP("no-verify", "", false, "Skip build verification")
+	spawnCmd.Flags().StringSliceP("params", "", []string{}, "Set build recipe parameters in the format KEY:VALUE")
 }
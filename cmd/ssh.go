@@ -26,7 +26,8 @@ var sshCmd = &cobra.Command{
 		}
 		providerFlag = globalConfig.Settings.Provider
 
-		vmInfo := models.GetVMInfo(providerFlag, boxName, globalConfig)
+		newController := controller.NewController(globalConfig)
+		vmInfo := models.GetVMInfo(providerFlag, boxName, newController.Configs)
 		if vmInfo == nil {
 			utils.Log.Fatal("Provider or custom VM not found")
 		}
@@ -38,7 +39,6 @@ var sshCmd = &cobra.Command{
 			vmInfo.Username = usernameFlag
 		}
 
-		newController := controller.NewController(globalConfig)
 		newController.SSH(boxName, vmInfo.Username, vmInfo.Password, vmInfo.Port, vmInfo.KeyPath)
 	},
 }
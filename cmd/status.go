@@ -59,24 +59,30 @@ Examples:
 			Label  string
 			Status string
 			IP     string
+			Region string
 		})
 
 		for _, box := range boxes {
-			if fleetFilter != "" && !utils.MatchesFleetName(box.Label, fleetFilter) {
+			if fleetFilter != "" && !utils.BoxInFleet(box.Label, box.Tags, fleetFilter, provider == controller.PROVIDER_DIGITALOCEAN, globalConfig.Settings.LegacyFleetMatch) {
 				continue
 			}
 
-			fleetName := extractFleetName(box.Label)
+			fleetName := utils.FleetFromTags(box.Tags)
+			if fleetName == "" {
+				fleetName = extractFleetName(box.Label)
+			}
 			fleets[fleetName] = append(fleets[fleetName], struct {
 				ID     string
 				Label  string
 				Status string
 				IP     string
+				Region string
 			}{
 				ID:     box.ID,
 				Label:  box.Label,
 				Status: box.Status,
 				IP:     box.IP,
+				Region: box.Region,
 			})
 		}
 
@@ -110,7 +116,7 @@ Examples:
 			fmt.Printf("Fleet: %s (%d/%d running)\n", fleetName, running, total)
 
 			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"Label", "Status", "IP"})
+			table.SetHeader([]string{"Label", "Status", "IP", "Region"})
 			table.SetBorder(false)
 
 			for _, inst := range instances {
@@ -120,7 +126,7 @@ Examples:
 				} else {
 					status = strings.ToUpper(status)
 				}
-				table.Append([]string{inst.Label, status, inst.IP})
+				table.Append([]string{inst.Label, status, inst.IP, inst.Region})
 			}
 
 			table.Render()
@@ -144,6 +150,7 @@ func printSummary(fleets map[string][]struct {
 	Label  string
 	Status string
 	IP     string
+	Region string
 }) {
 	totalInstances := 0
 	totalRunning := 0
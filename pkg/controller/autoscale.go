@@ -0,0 +1,567 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	p "github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/sshutils"
+	"github.com/FleexSecurity/fleex/pkg/ui"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+const (
+	// autoscaleInterval is the minimum time between two scaling decisions
+	autoscaleInterval = 30 * time.Second
+	// autoscaleBacklogPerBox is the number of queued chunks per box above
+	// which boxes are added
+	autoscaleBacklogPerBox = 2
+	// autoscaleMaxFailures stops spawning after this many batches in a row
+	// gave no working box
+	autoscaleMaxFailures = 3
+)
+
+// chunkQueue holds the input chunks waiting for a box
+type chunkQueue struct {
+	mu     sync.Mutex
+	chunks []string
+}
+
+func (q *chunkQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.chunks) == 0 {
+		return "", false
+	}
+	chunk := q.chunks[0]
+	q.chunks = q.chunks[1:]
+	return chunk, true
+}
+
+func (q *chunkQueue) push(chunk string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *chunkQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.chunks)
+}
+
+// autoscaler runs the boxes of a queue-based scan, adding boxes while the
+// backlog is high and retiring them once the queue is drained
+type autoscaler struct {
+	c         Controller
+	opts      models.AutoscaleOptions
+	fleetName string
+	queue     *chunkQueue
+	// retireAll also deletes the boxes that were in the fleet before the scan
+	retireAll bool
+	// process runs one chunk on a box
+	process func(conn *sshutils.Connection, transfer sshutils.Transferer, box p.Box, chunk string) error
+	results *boxResultSet
+
+	mu       sync.Mutex
+	active   int
+	spawning bool
+	failures int
+	boxes    []p.Box
+	started  map[string]time.Time
+	stopped  map[string]time.Time
+	warned   bool
+	idle     chan struct{}
+}
+
+func newAutoscaler(c Controller, fleetName string, opts models.AutoscaleOptions, queue *chunkQueue) *autoscaler {
+	return &autoscaler{
+		c:         c,
+		opts:      opts,
+		fleetName: fleetName,
+		queue:     queue,
+		results:   &boxResultSet{},
+		started:   make(map[string]time.Time),
+		stopped:   make(map[string]time.Time),
+		idle:      make(chan struct{}, 1),
+	}
+}
+
+// run starts a worker on every box of fleet and scales the fleet until the
+// queue is drained and every worker is done
+func (a *autoscaler) run(fleet []p.Box) {
+	now := time.Now()
+	for _, box := range fleet {
+		a.mu.Lock()
+		a.active++
+		a.mu.Unlock()
+		a.startWorker(box, now, false)
+	}
+	if len(fleet) < a.opts.Min {
+		a.spawn(a.opts.Min - len(fleet))
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	var lastScale time.Time
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.idle:
+		}
+
+		backlog := a.queue.len()
+		a.mu.Lock()
+		active, spawning, failures := a.active, a.spawning, a.failures
+		a.mu.Unlock()
+
+		if active == 0 && (backlog == 0 || failures >= autoscaleMaxFailures || !a.canSpawn(1)) {
+			if backlog > 0 {
+				utils.Log.Errorf("No box left to scan, %d chunks were not processed", backlog)
+			}
+			return
+		}
+		if backlog == 0 || spawning || failures >= autoscaleMaxFailures {
+			continue
+		}
+		if active > 0 && time.Since(lastScale) < autoscaleInterval {
+			continue
+		}
+		if backlog <= active*autoscaleBacklogPerBox || active >= a.opts.Max {
+			continue
+		}
+
+		add := (backlog+autoscaleBacklogPerBox-1)/autoscaleBacklogPerBox - active
+		if add > a.opts.Max-active {
+			add = a.opts.Max - active
+		}
+		for add > 0 && !a.canSpawn(add) {
+			add--
+		}
+		if add == 0 {
+			a.mu.Lock()
+			if !a.warned {
+				utils.Log.Warnf("Budget of $%.2f reached, not adding boxes", a.opts.Budget)
+				a.warned = true
+			}
+			a.mu.Unlock()
+			continue
+		}
+
+		utils.Log.Infof("Backlog of %d chunks on %d boxes, adding %d boxes", backlog, active, add)
+		lastScale = time.Now()
+		a.spawn(add)
+	}
+}
+
+// cost returns the estimated cost of every box used so far
+func (a *autoscaler) cost() float64 {
+	hours := 0.0
+	for label, start := range a.started {
+		end, ok := a.stopped[label]
+		if !ok {
+			end = time.Now()
+		}
+		hours += end.Sub(start).Hours()
+	}
+	return hours * a.opts.HourlyCost
+}
+
+// canSpawn reports whether n more boxes fit in the budget, counting at least
+// one hour for each
+func (a *autoscaler) canSpawn(n int) bool {
+	if a.opts.Budget <= 0 {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.cost()+float64(n)*a.opts.HourlyCost <= a.opts.Budget
+}
+
+// spawn adds n boxes to the fleet in the background. They get work once
+// running and built. Only one batch is spawned at a time.
+func (a *autoscaler) spawn(n int) {
+	a.mu.Lock()
+	a.active += n
+	a.spawning = true
+	a.mu.Unlock()
+
+	go func() {
+		boxes := a.spawnBoxes(n)
+
+		a.mu.Lock()
+		a.active -= n - len(boxes)
+		a.spawning = false
+		if len(boxes) == 0 {
+			a.failures++
+		} else {
+			a.failures = 0
+		}
+		a.mu.Unlock()
+
+		for _, box := range boxes {
+			a.startWorker(box, time.Time{}, true)
+		}
+		a.notify()
+	}()
+}
+
+// spawnBoxes creates n boxes and returns the ones that are running and built
+func (a *autoscaler) spawnBoxes(n int) []p.Box {
+	known := make(map[string]bool)
+	for _, box := range a.c.GetFleet(a.fleetName) {
+		known[box.ID] = true
+	}
+
+	spawnedAt := time.Now()
+	if err := a.c.Service.SpawnFleet(a.fleetName, n); err != nil {
+		utils.Log.Error("Failed to add boxes: ", err)
+		return nil
+	}
+
+	report, err := a.c.waitBoxesReady(a.fleetName, known, n, nil)
+	if err != nil {
+		utils.Log.Warn(err)
+	}
+	ready := report.Ready
+	a.mu.Lock()
+	for _, box := range ready {
+		a.started[box.Label] = spawnedAt
+	}
+	a.mu.Unlock()
+
+	if len(ready) == 0 || a.opts.Recipe == nil {
+		return ready
+	}
+
+	labels := make([]string, len(ready))
+	for i, box := range ready {
+		labels[i] = box.Label
+	}
+	results, err := a.c.BuildFleet(models.BuildOptions{
+		Recipe:    a.opts.Recipe,
+		FleetName: a.fleetName,
+		Boxes:     labels,
+		Parallel:  5,
+	})
+	if err != nil {
+		utils.Log.Error("Failed to build new boxes: ", err)
+		for _, box := range ready {
+			a.retire(box)
+		}
+		return nil
+	}
+
+	built := make(map[string]bool)
+	for _, r := range results {
+		built[r.BoxName] = r.Success
+	}
+	var good []p.Box
+	for _, box := range ready {
+		if built[box.Label] {
+			good = append(good, box)
+		} else {
+			utils.Log.Warnf("%s failed to build, deleting it", box.Label)
+			a.retire(box)
+		}
+	}
+	return good
+}
+
+// startWorker processes chunks on box until the queue is empty. Boxes added
+// by the autoscaler are deleted once idle.
+func (a *autoscaler) startWorker(box p.Box, start time.Time, spawned bool) {
+	a.mu.Lock()
+	a.boxes = append(a.boxes, box)
+	if !start.IsZero() {
+		a.started[box.Label] = start
+	}
+	a.mu.Unlock()
+
+	go func() {
+		err := a.work(box)
+		a.results.add(box.Label, err)
+		if err != nil {
+			utils.Log.Errorf("%s: %v", box.Label, err)
+		}
+
+		if spawned || a.retireAll {
+			a.retire(box)
+		}
+
+		a.mu.Lock()
+		a.active--
+		a.mu.Unlock()
+		a.notify()
+	}()
+}
+
+func (a *autoscaler) work(box p.Box) error {
+	port := a.c.Configs.Providers[a.c.Configs.Settings.Provider].Port
+	username := a.c.Configs.Providers[a.c.Configs.Settings.Provider].Username
+
+	conn, err := a.c.connectWithRetry(box.IP+":"+strconv.Itoa(port), username, a.c.Configs.SSHKeys.PrivateFile)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	transfer, err := a.c.newTransferer(conn, box)
+	if err != nil {
+		return err
+	}
+	defer transfer.Close()
+
+	for {
+		chunk, ok := a.queue.pop()
+		if !ok {
+			return nil
+		}
+		if err := a.process(conn, transfer, box, chunk); err != nil {
+			// Another box will pick the chunk up
+			a.queue.push(chunk)
+			return err
+		}
+	}
+}
+
+// retire deletes a box and stops counting its cost
+func (a *autoscaler) retire(box p.Box) {
+	if err := a.c.Service.DeleteBoxByID(box.ID); err != nil {
+		utils.Log.Errorf("Failed to delete %s: %v", box.Label, err)
+	} else {
+		utils.Log.Debug("Killed box ", box.Label)
+	}
+
+	a.mu.Lock()
+	a.stopped[box.Label] = time.Now()
+	a.mu.Unlock()
+}
+
+func (a *autoscaler) notify() {
+	select {
+	case a.idle <- struct{}{}:
+	default:
+	}
+}
+
+// splitQueue splits inputFile into chunks of chunkSize lines in outputDir
+func splitQueue(inputFile, outputDir string, chunkSize int, strategy string, compress bool) ([]string, error) {
+	lines, err := utils.CountFileLines(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if lines == 0 {
+		return nil, fmt.Errorf("input file is empty, nothing to scan")
+	}
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+
+	count := (lines + chunkSize - 1) / chunkSize
+	chunkFiles := make([]string, count)
+	weights := make([]int, count)
+	for i := range chunkFiles {
+		chunkFiles[i] = filepath.Join(outputDir, fmt.Sprintf("part-%06d", i+1))
+		if compress {
+			chunkFiles[i] += ".gz"
+		}
+		weights[i] = 1
+	}
+
+	counts, err := utils.ShardFile(inputFile, chunkFiles, strategy, weights, compress)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []string
+	for i, n := range counts {
+		if n > 0 {
+			chunks = append(chunks, chunkFiles[i])
+		} else {
+			os.Remove(chunkFiles[i])
+		}
+	}
+	return chunks, nil
+}
+
+// AutoscaleStart runs a horizontal scan from a queue of input chunks, growing
+// the fleet between the autoscale bounds while the backlog is high
+func (c Controller) AutoscaleStart(fleetName, command string, delete bool, input, outputPath, chunksFolder string, module *models.Module, scale models.AutoscaleOptions, diff models.DiffOptions, stream models.StreamOptions) {
+	start := time.Now()
+	provider := c.Configs.Settings.Provider
+	providerId := GetProvider(provider)
+	if providerId == PROVIDER_CUSTOM {
+		utils.Log.Fatal(models.ErrNotAvailableCustomVps)
+	}
+
+	if val, ok := module.Vars["INPUT"]; ok {
+		input = val
+	}
+	if val, ok := module.Vars["OUTPUT"]; ok {
+		outputPath = val
+	}
+	if input == "" || outputPath == "" {
+		utils.Log.Fatal("INPUT and OUTPUT are required (use -i and -o flags, or set in module)")
+	}
+
+	timeStamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+	tempFolder := filepath.Join("/tmp", "fleex-"+timeStamp)
+	if chunksFolder != "" {
+		tempFolder = chunksFolder
+	}
+	tempFolderInput := filepath.Join(tempFolder, "input")
+	utils.MakeFolder(tempFolder)
+	utils.MakeFolder(tempFolderInput)
+
+	findings, err := newFindingStream(stream)
+	if err != nil {
+		utils.Log.Fatal(err)
+	}
+	defer findings.Close()
+
+	fleet := c.GetFleet(fleetName)
+	if len(fleet) == 0 {
+		if err := c.newFleetKeys(fleetName); err != nil {
+			utils.Log.Fatal("Failed to generate the fleet SSH key: ", err)
+		}
+	}
+	c = c.withFleetKeys(fleetName)
+	if len(fleet) > scale.Max {
+		utils.Log.Warnf("Fleet %s has %d boxes, more than the autoscale maximum of %d", fleetName, len(fleet), scale.Max)
+	}
+
+	// Files referenced by vars are sent to boxes added later as well
+	varFiles := make(map[string]string)
+	for key, value := range module.Vars {
+		if key != "INPUT" && key != "OUTPUT" && isFile(value) {
+			remote, err := remoteVarFile(value)
+			if err != nil {
+				utils.Log.Fatal(err)
+			}
+			module.Vars[key] = remote
+			varFiles[value] = remote
+		}
+	}
+
+	chunks, err := splitQueue(input, tempFolderInput, scale.ChunkSize, module.Shard, module.CompressChunks)
+	if err != nil {
+		utils.Log.Fatal(err)
+	}
+	utils.Log.Infof("Scan started! %d chunks queued", len(chunks))
+
+	queue := &chunkQueue{chunks: chunks}
+	a := newAutoscaler(c, fleetName, scale, queue)
+	a.retireAll = delete
+
+	var varsMu sync.Mutex
+	sentVars := make(map[string]bool)
+
+	a.process = func(conn *sshutils.Connection, transfer sshutils.Transferer, box p.Box, chunk string) error {
+		varsMu.Lock()
+		sent := sentVars[box.Label]
+		varsMu.Unlock()
+		if !sent {
+			for local, remote := range varFiles {
+				if err := transfer.Upload(local, remote); err != nil {
+					return fmt.Errorf("failed to send %s: %v", local, err)
+				}
+			}
+			varsMu.Lock()
+			sentVars[box.Label] = true
+			varsMu.Unlock()
+		}
+
+		part := filepath.Base(chunk)
+		if ext := filepath.Ext(part); ext == ".gz" {
+			part = part[:len(part)-len(ext)]
+		}
+		chunkInputFile := "/tmp/fleex-" + timeStamp + "-" + part
+		chunkOutputFile := "/tmp/fleex-" + timeStamp + "-out-" + part
+		localOutput := filepath.Join(tempFolder, "chunk-out-"+part)
+
+		if err := sendChunk(conn, transfer, chunk, chunkInputFile); err != nil {
+			return fmt.Errorf("failed to send %s: %v", part, err)
+		}
+
+		localVars := make(map[string]string)
+		for k, v := range module.Vars {
+			localVars[k] = v
+		}
+		localVars["INPUT"] = chunkInputFile
+		localVars["OUTPUT"] = chunkOutputFile
+		finalCommand, err := ReplaceCommandVars(command, localVars)
+		if err != nil {
+			return err
+		}
+
+		stopStream := func() {}
+		if findings != nil {
+			stopStream = findings.tail(conn, box.Label, chunkOutputFile)
+		}
+
+		exitCode, err := conn.RunLines(finalCommand, func(line string, stderr bool) {
+			ui.BoxOutput(box.Label, line, stderr)
+		})
+		if err != nil {
+			stopStream()
+			return err
+		}
+		if exitCode != 0 {
+			utils.Log.Warnf("%s: command exited with status %d on %s", box.Label, exitCode, part)
+		}
+
+		err = transfer.Download(chunkOutputFile, localOutput)
+		stopStream()
+		if err != nil {
+			utils.Log.Warnf("%s: no output received for %s (remote file may not exist)", box.Label, part)
+		} else if findings != nil {
+			findings.addFile(box.Label, localOutput)
+		}
+
+		// Remove the chunk files from the box to save space
+		conn.RunLines("rm -f "+chunkInputFile+" "+chunkOutputFile, func(string, bool) {})
+		return nil
+	}
+
+	a.run(fleet)
+	findings.Close()
+	if delete {
+		c.deleteFleetKeys(fleetName)
+	}
+
+	a.mu.Lock()
+	cost := a.cost()
+	boxes := a.boxes
+	a.mu.Unlock()
+
+	utils.Log.Info("Scan done! Took ", time.Since(start), ". Output file: ", outputPath)
+	if scale.HourlyCost > 0 {
+		utils.Log.Infof("Used %d boxes, estimated cost $%.2f", len(boxes), cost)
+	}
+
+	utils.RunCommand("cat "+filepath.Join(tempFolder, "chunk-out-*")+" > "+outputPath, true)
+
+	c.completeRun(&models.RunRecord{
+		Kind:     models.RunKindScan,
+		Workflow: scanName(module),
+		Command:  command,
+		Input:    input,
+		Output:   outputPath,
+		Vars:     module.Vars,
+		Fleet:    newRunFleet(fleetName, boxes),
+		Started:  start,
+		Results:  a.results.results,
+	}, diff)
+
+	if chunksFolder == "" {
+		os.RemoveAll(tempFolder)
+	}
+}
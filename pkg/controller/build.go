@@ -3,12 +3,12 @@ package controller
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/hnakamur/go-scp"
-
 	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/notify"
 	"github.com/FleexSecurity/fleex/pkg/provider"
 	"github.com/FleexSecurity/fleex/pkg/sshutils"
 	"github.com/FleexSecurity/fleex/pkg/ui"
@@ -16,30 +16,56 @@ import (
 )
 
 func (c Controller) BuildFleet(opts models.BuildOptions) ([]models.BuildResult, error) {
+	c = c.withFleetKeys(opts.FleetName)
+	if err := validateBuildVars(opts.Recipe); err != nil {
+		return nil, err
+	}
+
 	fleet := c.GetFleet(opts.FleetName)
 	if len(fleet) == 0 {
 		return nil, fmt.Errorf("fleet %s not found", opts.FleetName)
 	}
-
-	providerName := c.Configs.Settings.Provider
-	port := c.Configs.Providers[providerName].Port
-	username := c.Configs.Providers[providerName].Username
-	privateKeyPath := c.Configs.SSHKeys.PrivateFile
-
-	results := make([]models.BuildResult, len(fleet))
+	if len(opts.Boxes) > 0 {
+		fleet = filterBoxes(fleet, opts.Boxes)
+		if len(fleet) == 0 {
+			return nil, fmt.Errorf("none of the boxes to build are in fleet %s", opts.FleetName)
+		}
+	}
 
 	if opts.DryRun {
 		ui.Info("Dry run mode - showing what would be executed:")
 		for _, step := range opts.Recipe.Steps {
 			fmt.Printf("  Step: %s\n", step.Name)
 			for _, cmd := range step.Commands {
-				cmdExpanded := utils.ReplaceBuildVars(cmd, opts.Recipe.Vars)
+				cmdExpanded, err := utils.ReplaceBuildVars(cmd, opts.Recipe.Vars)
+				if err != nil {
+					return nil, err
+				}
 				fmt.Printf("    $ %s\n", cmdExpanded)
 			}
 		}
-		return results, nil
+		return make([]models.BuildResult, len(fleet)), nil
 	}
 
+	results := c.buildBoxes(fleet, opts)
+	if opts.ReplaceFailed {
+		results = c.replaceFailedBuilds(opts, results)
+	}
+
+	c.notifyBuildFailures(opts, results)
+
+	return results, nil
+}
+
+// buildBoxes runs the recipe on the boxes of fleet, opts.Parallel at a time
+func (c Controller) buildBoxes(fleet []provider.Box, opts models.BuildOptions) []models.BuildResult {
+	providerName := c.Configs.Settings.Provider
+	port := c.Configs.Providers[providerName].Port
+	username := c.Configs.Providers[providerName].Username
+	privateKeyPath := c.Configs.SSHKeys.PrivateFile
+
+	results := make([]models.BuildResult, len(fleet))
+
 	progress := ui.NewBuildProgress(len(fleet))
 	progress.Start(opts.Recipe.Name)
 
@@ -94,7 +120,131 @@ func (c Controller) BuildFleet(opts models.BuildOptions) ([]models.BuildResult,
 
 	progress.Done()
 
-	return results, nil
+	return results
+}
+
+// replaceFailedBuilds deletes the boxes that failed the build, spawns new
+// boxes with the same labels and builds them, up to the replace retries
+func (c Controller) replaceFailedBuilds(opts models.BuildOptions, results []models.BuildResult) []models.BuildResult {
+	index := make(map[string]int, len(results))
+	for i, r := range results {
+		index[r.BoxName] = i
+	}
+
+	for attempt := 0; attempt < c.replaceRetries(); attempt++ {
+		fleet, err := c.Service.GetFleet(opts.FleetName)
+		if err != nil {
+			utils.Log.Error(err)
+			break
+		}
+		known := make(map[string]bool, len(fleet))
+		var failed []provider.Box
+		for _, box := range fleet {
+			known[box.ID] = true
+			if i, ok := index[box.Label]; ok && !results[i].Success {
+				failed = append(failed, box)
+			}
+		}
+		if len(failed) == 0 {
+			break
+		}
+
+		for _, box := range failed {
+			ui.Warning(fmt.Sprintf("Replacing %s after failed build", box.Label))
+		}
+		if err := c.replaceBoxes(opts.FleetName, failed); err != nil {
+			utils.Log.Error(err)
+			break
+		}
+		report, err := c.waitBoxesReady(opts.FleetName, known, len(failed), nil)
+		if err != nil {
+			utils.Log.Error(err)
+		}
+		if len(report.Ready) == 0 {
+			break
+		}
+
+		for _, r := range c.buildBoxes(report.Ready, opts) {
+			r.Replaced = true
+			if i, ok := index[r.BoxName]; ok {
+				results[i] = r
+			}
+		}
+	}
+	return results
+}
+
+// filterBoxes keeps the boxes of fleet whose label is in labels
+func filterBoxes(fleet []provider.Box, labels []string) []provider.Box {
+	wanted := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		wanted[label] = true
+	}
+
+	var filtered []provider.Box
+	for _, box := range fleet {
+		if wanted[box.Label] {
+			filtered = append(filtered, box)
+		}
+	}
+	return filtered
+}
+
+// notifyBuildFailures sends a summary of the boxes that failed the build
+func (c Controller) notifyBuildFailures(opts models.BuildOptions, results []models.BuildResult) {
+	var failures []string
+	for _, r := range results {
+		if r.Success {
+			continue
+		}
+		failure := r.BoxName
+		for _, step := range r.Steps {
+			if !step.Success {
+				failure += fmt.Sprintf(" [step %s]", step.StepName)
+				break
+			}
+		}
+		if r.Error != nil {
+			failure += ": " + r.Error.Error()
+		}
+		failures = append(failures, failure)
+	}
+	if len(failures) == 0 {
+		return
+	}
+
+	c.sendNotification(notify.Event{
+		Type:    notify.EventBuildFailed,
+		Title:   fmt.Sprintf("Build %s failed on %d/%d boxes of %s", opts.Recipe.Name, len(failures), len(results), opts.FleetName),
+		Message: strings.Join(failures, "\n"),
+	})
+}
+
+// validateBuildVars applies the recipe params to its vars and makes sure every
+// {vars.X} placeholder can be resolved before anything runs on the fleet
+func validateBuildVars(recipe *models.BuildRecipe) error {
+	if recipe.Vars == nil {
+		recipe.Vars = make(map[string]string)
+	}
+
+	if err := utils.ApplyParams(recipe.Params, recipe.Vars); err != nil {
+		return fmt.Errorf("invalid recipe params: %w", err)
+	}
+
+	var texts []string
+	for _, step := range recipe.Steps {
+		texts = append(texts, step.Commands...)
+	}
+	for _, file := range recipe.Files {
+		texts = append(texts, file.Source, file.Destination)
+	}
+
+	for _, text := range texts {
+		if _, err := utils.ReplaceBuildVars(text, recipe.Vars); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c Controller) buildBox(box *provider.Box, opts models.BuildOptions, port int, username, privateKeyPath string) models.BuildResult {
@@ -106,7 +256,7 @@ func (c Controller) waitForSSH(ip string, port int, username, privateKeyPath str
 	var err error
 
 	for i := 0; i < maxRetries; i++ {
-		conn, err = sshutils.Connect(ip+":"+strconv.Itoa(port), username, privateKeyPath)
+		conn, err = c.dialer.Connect(ip+":"+strconv.Itoa(port), username, privateKeyPath)
 		if err == nil {
 			return conn, nil
 		}
@@ -134,12 +284,32 @@ func (c Controller) buildBoxWithProgress(box *provider.Box, opts models.BuildOpt
 		return result
 	}
 
+	var transfer sshutils.Transferer
+	if len(opts.Recipe.Files) > 0 {
+		transfer, err = c.newTransferer(conn, *box)
+		if err != nil {
+			result.Error = fmt.Errorf("file transfer failed: %v", err)
+			result.Duration = time.Since(start)
+			return result
+		}
+		defer transfer.Close()
+	}
+
 	for _, file := range opts.Recipe.Files {
-		srcPath := utils.ExpandPath(file.Source)
-		srcPath = utils.ReplaceBuildVars(srcPath, opts.Recipe.Vars)
-		dstPath := utils.ReplaceBuildVars(file.Destination, opts.Recipe.Vars)
+		srcPath, err := utils.ReplaceBuildVars(utils.ExpandPath(file.Source), opts.Recipe.Vars)
+		if err != nil {
+			result.Error = err
+			result.Duration = time.Since(start)
+			return result
+		}
+		dstPath, err := utils.ReplaceBuildVars(file.Destination, opts.Recipe.Vars)
+		if err != nil {
+			result.Error = err
+			result.Duration = time.Since(start)
+			return result
+		}
 
-		err := scp.NewSCP(conn.Client).SendFile(srcPath, dstPath)
+		err = transfer.Upload(srcPath, dstPath)
 		if err != nil {
 			result.Error = fmt.Errorf("file transfer failed: %v", err)
 			result.Duration = time.Since(start)
@@ -192,9 +362,14 @@ func (c Controller) executeStep(box *provider.Box, step models.BuildStep, opts m
 		allCommandsSuccess := true
 
 		for _, cmd := range step.Commands {
-			cmdExpanded := utils.ReplaceBuildVars(cmd, opts.Recipe.Vars)
+			cmdExpanded, err := utils.ReplaceBuildVars(cmd, opts.Recipe.Vars)
+			if err != nil {
+				allCommandsSuccess = false
+				result.Output = err.Error()
+				break
+			}
 
-			_, err := sshutils.RunCommandSilent(cmdExpanded, box.IP, port, username, privateKeyPath)
+			_, err = c.dialer.RunCommandSilent(cmdExpanded, box.IP, port, username, privateKeyPath)
 			if err != nil {
 				allCommandsSuccess = false
 				result.Output = fmt.Sprintf("command failed: %s - %v", cmdExpanded, err)
@@ -219,11 +394,12 @@ func (c Controller) executeStep(box *provider.Box, step models.BuildStep, opts m
 }
 
 func (c Controller) runVerify(box *provider.Box, verify models.VerifyStep, opts models.BuildOptions, port int, username, privateKeyPath string) bool {
-	_, err := sshutils.RunCommandSilent(verify.Command, box.IP, port, username, privateKeyPath)
+	_, err := c.dialer.RunCommandSilent(verify.Command, box.IP, port, username, privateKeyPath)
 	return err == nil
 }
 
 func (c Controller) VerifyFleet(opts models.BuildOptions) (map[string]bool, error) {
+	c = c.withFleetKeys(opts.FleetName)
 	fleet := c.GetFleet(opts.FleetName)
 	if len(fleet) == 0 {
 		return nil, fmt.Errorf("fleet %s not found", opts.FleetName)
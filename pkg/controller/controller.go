@@ -5,7 +5,6 @@ import (
 	"io/ioutil"
 	"os"
 	"os/signal"
-	"path"
 	"runtime"
 	"strconv"
 	"strings"
@@ -17,8 +16,10 @@ import (
 
 	"github.com/FleexSecurity/fleex/config"
 	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/notify"
 	"github.com/FleexSecurity/fleex/pkg/provider"
 	"github.com/FleexSecurity/fleex/pkg/services"
+	"github.com/FleexSecurity/fleex/pkg/sshutils"
 	"github.com/FleexSecurity/fleex/pkg/ui"
 	"github.com/FleexSecurity/fleex/pkg/utils"
 )
@@ -37,6 +38,7 @@ var log = logrus.New()
 type Controller struct {
 	Service provider.Provider
 	Configs *models.Config
+	dialer  *sshutils.Dialer
 }
 
 func GetProvider(name string) Provider {
@@ -57,8 +59,20 @@ func GetProvider(name string) Provider {
 }
 
 func NewController(configs *models.Config) Controller {
+	// The provider may have been switched with -P after the config was loaded
+	configs, err := utils.ResolveProviderSecrets(configs, configs.Settings.Provider)
+	if err != nil {
+		utils.Log.Fatal("Failed to resolve secrets: ", err)
+	}
+
+	dialer, err := sshutils.NewDialer(configs)
+	if err != nil {
+		utils.Log.Fatal(err)
+	}
+
 	c := Controller{
 		Configs: configs,
+		dialer:  dialer,
 	}
 	selectedProvider := configs.Settings.Provider
 	providerId := GetProvider(selectedProvider)
@@ -68,21 +82,25 @@ func NewController(configs *models.Config) Controller {
 	case PROVIDER_CUSTOM:
 		c.Service = services.CustomService{
 			Configs: configs,
+			Dialer:  dialer,
 		}
 	case PROVIDER_LINODE:
 		c.Service = services.LinodeService{
 			Client:  config.GetLinodeClient(token),
 			Configs: configs,
+			Dialer:  dialer,
 		}
 	case PROVIDER_DIGITALOCEAN:
 		c.Service = services.DigitaloceanService{
 			Client:  config.GetDigitaloaceanToken(token),
 			Configs: configs,
+			Dialer:  dialer,
 		}
 	case PROVIDER_VULTR:
 		c.Service = services.VultrService{
 			Client:  config.GetVultrClient(token),
 			Configs: configs,
+			Dialer:  dialer,
 		}
 	default:
 		utils.Log.Fatal(models.ErrInvalidProvider)
@@ -99,7 +117,7 @@ func (c Controller) ListBoxes(token string, provider Provider) {
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ID", "Label", "Group", "Status", "IP"})
+	table.SetHeader([]string{"ID", "Label", "Group", "Status", "IP", "Region"})
 
 	for _, box := range boxes {
 		table.Append([]string{
@@ -108,6 +126,7 @@ func (c Controller) ListBoxes(token string, provider Provider) {
 			box.Group,
 			box.Status,
 			box.IP,
+			box.Region,
 		})
 	}
 
@@ -127,6 +146,7 @@ func (c Controller) DeleteFleet(name string) {
 	for len(c.GetFleet(name)) > 0 {
 		time.Sleep(1 * time.Second)
 	}
+	c.deleteFleetKeys(name)
 	utils.Log.Info("Fleet/Box deleted!")
 }
 
@@ -188,17 +208,6 @@ func (c Controller) GetBox(boxName string) (provider.Box, error) {
 	return c.Service.GetBox(boxName)
 }
 
-func (c Controller) RunCommand(name, command string) {
-	provider := c.Configs.Settings.Provider
-	port := c.Configs.Providers[provider].Port
-	username := c.Configs.Providers[provider].Username
-	password := c.Configs.Providers[provider].Password
-	err := c.Service.RunCommand(name, command, port, username, password)
-	if err != nil {
-		utils.Log.Fatal(err)
-	}
-}
-
 func (c Controller) DeleteBoxByID(id string, token string, provider Provider) {
 	err := c.Service.DeleteBoxByID(id)
 	if err != nil {
@@ -207,10 +216,10 @@ func (c Controller) DeleteBoxByID(id string, token string, provider Provider) {
 }
 
 func (c Controller) SpawnFleet(fleetName string, fleetCount int, skipWait bool, build bool) {
+	start := time.Now()
 	startFleet := c.GetFleet(fleetName)
 	finalFleetSize := len(startFleet) + fleetCount
 	selectedProvider := c.Configs.Settings.Provider
-	providerId := GetProvider(selectedProvider)
 
 	progress := ui.NewSpawnProgress(fleetCount)
 	progress.Start()
@@ -230,6 +239,14 @@ func (c Controller) SpawnFleet(fleetName string, fleetCount int, skipWait bool,
 		}
 	}()
 
+	// Every new fleet gets its own keypair, boxes added later reuse it
+	if len(startFleet) == 0 {
+		if err := c.newFleetKeys(fleetName); err != nil {
+			utils.Log.Fatal("Failed to generate the fleet SSH key: ", err)
+		}
+	}
+	c = c.withFleetKeys(fleetName)
+
 	progress.StartSpawning()
 	err := c.Service.SpawnFleet(fleetName, fleetCount)
 	if err != nil {
@@ -237,32 +254,40 @@ func (c Controller) SpawnFleet(fleetName string, fleetCount int, skipWait bool,
 	}
 	progress.SpawningDone()
 
+	var replaced, failed []string
 	if !skipWait {
 		progress.StartWaiting()
-		for {
-			stillNotReady := false
-			fleet := c.GetFleet(fleetName)
-			if len(fleet) == finalFleetSize {
-				for i := range fleet {
-					progress.UpdateBoxStatus(fleet[i].Label, fleet[i].Status, fleet[i].IP)
-					if (providerId == PROVIDER_DIGITALOCEAN && fleet[i].Status != "active") || (providerId == PROVIDER_LINODE && fleet[i].Status != "running") || (providerId == PROVIDER_VULTR && fleet[i].Status != "active") {
-						stillNotReady = true
-					}
-				}
-
-				if stillNotReady {
-					time.Sleep(5 * time.Second)
-				} else {
-					break
-				}
-			} else {
-				time.Sleep(3 * time.Second)
-			}
+		known := make(map[string]bool)
+		for _, box := range startFleet {
+			known[box.ID] = true
 		}
+		report, err := c.waitBoxesReady(fleetName, known, fleetCount, progress)
 		progress.WaitingDone()
+		if err != nil {
+			utils.Log.Error(err)
+		}
+		finalFleetSize = len(startFleet) + len(report.Ready)
+		replaced, failed = report.Replaced, report.Failed
 	}
 
+	ui.ReplacedBoxes(replaced, failed)
 	progress.Done()
+
+	event := notify.Event{
+		Type:  notify.EventSpawnComplete,
+		Title: fmt.Sprintf("Fleet %s spawned", fleetName),
+		Fields: map[string]string{
+			"boxes":    strconv.Itoa(finalFleetSize),
+			"provider": selectedProvider,
+			"duration": time.Since(start).Round(time.Second).String(),
+		},
+	}
+	if len(failed) > 0 {
+		event.Title = fmt.Sprintf("Fleet %s spawned, %d boxes failed", fleetName, len(failed))
+		event.Message = "Failed boxes: " + strings.Join(failed, ", ")
+		event.Fields["failed"] = strconv.Itoa(len(failed))
+	}
+	c.sendNotification(event)
 }
 
 func (c Controller) SSH(boxName, username, password string, port int, sshKey string) {
@@ -275,6 +300,7 @@ func (c Controller) SSH(boxName, username, password string, port int, sshKey str
 
 	if box.Label == boxName {
 		var authMethods []ssh.AuthMethod
+		sshKey = boxKeyPath(box, sshKey)
 
 		// Try to use SSH key if provided and readable
 		if sshKey != "" {
@@ -304,7 +330,7 @@ func (c Controller) SSH(boxName, username, password string, port int, sshKey str
 		}
 
 		addr := fmt.Sprintf("%s:%d", box.IP, port)
-		client, err := ssh.Dial("tcp", addr, config)
+		client, err := c.dialer.Dial(addr, config)
 		if err != nil {
 			utils.Log.Fatal(err)
 		}
@@ -343,55 +369,3 @@ func (c Controller) SSH(boxName, username, password string, port int, sshKey str
 		}
 	}
 }
-
-func SendSCP(source, destination, ip, username string, port int, privateKeyPath string) error {
-	key, err := ioutil.ReadFile(privateKeyPath)
-	if err != nil {
-		return err
-	}
-
-	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
-		return err
-	}
-	config := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", ip+":"+strconv.Itoa(port), config)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	data, err := ioutil.ReadFile(source)
-	if err != nil {
-		return err
-	}
-
-	session, err := client.NewSession()
-	if err != nil {
-		return err
-	}
-	defer session.Close()
-
-	go func() {
-		w, _ := session.StdinPipe()
-		defer w.Close()
-
-		content := string(data)
-		fmt.Fprintf(w, "C0644 %d %s\n", len(content), path.Base(destination))
-		fmt.Fprint(w, content)
-		fmt.Fprint(w, "\x00")
-	}()
-
-	if err := session.Run("/usr/bin/scp -tr " + path.Dir(destination)); err != nil {
-		return err
-	}
-
-	return nil
-}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"github.com/FleexSecurity/fleex/pkg/models"
+	p "github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/services"
+	"github.com/FleexSecurity/fleex/pkg/sshutils"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// withFleetKeys returns a controller connecting to boxes with the keypair of
+// the fleet, the shared keypair if the fleet has none
+func (c Controller) withFleetKeys(fleetName string) Controller {
+	keys := utils.FleetSSHKeys(fleetName, c.Configs.SSHKeys)
+	if keys == c.Configs.SSHKeys {
+		return c
+	}
+	configs := *c.Configs
+	configs.SSHKeys = keys
+	c.Configs = &configs
+	return c
+}
+
+// newFleetKeys generates the ed25519 keypair of a new fleet, replacing the
+// one a previous fleet with the same name may have left
+func (c Controller) newFleetKeys(fleetName string) error {
+	if GetProvider(c.Configs.Settings.Provider) == PROVIDER_CUSTOM {
+		return nil
+	}
+	if err := utils.ValidateFleetName(fleetName); err != nil {
+		return err
+	}
+	c.deleteFleetKeys(fleetName)
+
+	// The image only trusts the shared key for this user, the fleet would
+	// never become reachable with its own
+	if !services.InstallsFleetKey(c.Configs) {
+		utils.Log.Debugf("user_data doesn't install {vars.PUBLIC_KEY} for %s, fleet %s uses the shared key", c.Configs.Providers[c.Configs.Settings.Provider].Username, fleetName)
+		return nil
+	}
+
+	keys, err := utils.ClaimFleetKeys(fleetName)
+	if err != nil {
+		return err
+	}
+	return sshutils.GenerateEd25519KeyPair("fleex-"+fleetName, keys)
+}
+
+// deleteFleetKeys removes the keypair of a fleet and the key registered with
+// the provider. The local keypair is kept if the provider key can't be
+// removed, so that a later delete can retry.
+func (c Controller) deleteFleetKeys(fleetName string) {
+	if !utils.OwnsFleetKeys(fleetName) {
+		return
+	}
+	if err := c.Service.DeleteSSHKey(fleetName); err != nil {
+		utils.Log.Errorf("Failed to delete the SSH key of %s: %v", fleetName, err)
+		return
+	}
+	if err := utils.DeleteFleetKeys(fleetName); err != nil {
+		utils.Log.Errorf("Failed to delete the SSH key of %s: %v", fleetName, err)
+	}
+}
+
+// boxKeyPath returns the private key of the fleet a box was spawned in,
+// keyPath for boxes of fleets without their own keypair
+func boxKeyPath(box p.Box, keyPath string) string {
+	return utils.BoxSSHKeys(box.Tags, models.SSHKeys{PrivateFile: keyPath}).PrivateFile
+}
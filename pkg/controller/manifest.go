@@ -0,0 +1,423 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/ui"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// manifestController returns a controller for the manifest provider, with its
+// size, image, tags and TTL, spawning boxes in region if set
+func (c Controller) manifestController(m *models.FleetManifest, region string) Controller {
+	configs := *c.Configs
+	configs.Providers = make(map[string]models.Provider, len(c.Configs.Providers))
+	for name, p := range c.Configs.Providers {
+		configs.Providers[name] = p
+	}
+	configs.Settings.Provider = m.Provider
+
+	providerInfo := configs.Providers[m.Provider]
+	if region != "" {
+		providerInfo.Region = region
+		providerInfo.Regions = nil
+	}
+	if m.Size != "" {
+		providerInfo.Size = m.Size
+	}
+	if m.Image != "" {
+		providerInfo.Image = m.Image
+	}
+	if len(m.Tags) > 0 {
+		providerInfo.Tags = append(append([]string{}, providerInfo.Tags...), m.Tags...)
+	}
+	if m.TTL != "" {
+		vars := make(map[string]string, len(providerInfo.UserDataVars)+1)
+		for k, v := range providerInfo.UserDataVars {
+			vars[k] = v
+		}
+		vars["TTL"] = m.TTL
+		providerInfo.UserDataVars = vars
+	}
+	configs.Providers[m.Provider] = providerInfo
+
+	return NewController(&configs)
+}
+
+// manifestRecipe loads the build recipe of a manifest with its params applied
+func manifestRecipe(m *models.FleetManifest) (*models.BuildRecipe, error) {
+	recipe, err := utils.ReadBuildFile(m.Build)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recipe %s: %v", m.Build, err)
+	}
+	if recipe.Vars == nil {
+		recipe.Vars = make(map[string]string)
+	}
+	for k, v := range m.Params {
+		recipe.Vars[k] = v
+	}
+	return recipe, nil
+}
+
+// regionCounts splits the manifest count across its regions, giving the
+// remainder to the first ones
+func regionCounts(m *models.FleetManifest) ([]string, map[string]int) {
+	regions := m.RegionList()
+	if len(regions) == 0 {
+		return []string{""}, map[string]int{"": m.Count}
+	}
+
+	counts := make(map[string]int, len(regions))
+	for i, region := range regions {
+		counts[region] = m.Count / len(regions)
+		if i < m.Count%len(regions) {
+			counts[region]++
+		}
+	}
+	return regions, counts
+}
+
+// sortByLabel orders boxes by label, so that <name>-10 comes after <name>-9
+func sortByLabel(boxes []provider.Box) {
+	sort.Slice(boxes, func(i, j int) bool {
+		if len(boxes[i].Label) != len(boxes[j].Label) {
+			return len(boxes[i].Label) < len(boxes[j].Label)
+		}
+		return boxes[i].Label < boxes[j].Label
+	})
+}
+
+// PlanFleet compares the fleet of a manifest with its desired state. If verify
+// is set, boxes failing the verification of the build recipe are rebuilt.
+func (c Controller) PlanFleet(m *models.FleetManifest, verify bool) (*models.FleetPlan, error) {
+	if GetProvider(m.Provider) == -1 || GetProvider(m.Provider) == PROVIDER_CUSTOM {
+		return nil, fmt.Errorf("manifests are not supported for provider %s", m.Provider)
+	}
+
+	ctrl := c.manifestController(m, "")
+	fleet := ctrl.GetFleet(m.Name)
+	sortByLabel(fleet)
+
+	plan := &models.FleetPlan{Manifest: m, Current: len(fleet)}
+
+	state, err := utils.ReadManifestState(m.Name)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil && !state.Expires.IsZero() && time.Now().After(state.Expires) {
+		plan.Expired = true
+		for _, box := range fleet {
+			plan.Actions = append(plan.Actions, models.PlanAction{
+				Type:   models.PlanDelete,
+				BoxID:  box.ID,
+				Box:    box.Label,
+				Region: box.Region,
+				Reason: "ttl expired at " + state.Expires.Format(time.RFC3339),
+			})
+		}
+		return plan, nil
+	}
+
+	regions, desired := regionCounts(m)
+	multiRegion := regions[0] != ""
+
+	// Boxes in the wrong region or size can't be fixed in place
+	var misplaced []provider.Box
+	var misplacedReasons []string
+	kept := make(map[string][]provider.Box)
+	for _, box := range fleet {
+		region := ""
+		if multiRegion {
+			region = box.Region
+		}
+		_, wantedRegion := desired[region]
+		switch {
+		case m.Size != "" && box.Size != m.Size:
+			misplaced = append(misplaced, box)
+			misplacedReasons = append(misplacedReasons, fmt.Sprintf("size %s, want %s", box.Size, m.Size))
+		case !wantedRegion:
+			misplaced = append(misplaced, box)
+			misplacedReasons = append(misplacedReasons, fmt.Sprintf("region %s not in manifest", box.Region))
+		default:
+			kept[region] = append(kept[region], box)
+		}
+	}
+
+	var spawns []models.PlanAction
+	missing := 0
+	for _, region := range regions {
+		boxes := kept[region]
+		if extra := len(boxes) - desired[region]; extra > 0 {
+			for _, box := range boxes[len(boxes)-extra:] {
+				plan.Actions = append(plan.Actions, models.PlanAction{
+					Type:   models.PlanDelete,
+					BoxID:  box.ID,
+					Box:    box.Label,
+					Region: box.Region,
+					Reason: fmt.Sprintf("fleet has more than %d boxes", m.Count),
+				})
+			}
+			kept[region] = boxes[:len(boxes)-extra]
+		} else if extra < 0 {
+			missing += -extra
+			spawns = append(spawns, models.PlanAction{
+				Type:   models.PlanSpawn,
+				Region: region,
+				Count:  -extra,
+				Reason: fmt.Sprintf("%d/%d boxes", len(boxes), desired[region]),
+			})
+		}
+	}
+
+	// Misplaced boxes are replaced as long as new boxes are needed, the
+	// others are only deleted
+	for i, box := range misplaced {
+		action := models.PlanAction{
+			Type:   models.PlanDelete,
+			BoxID:  box.ID,
+			Box:    box.Label,
+			Region: box.Region,
+			Reason: misplacedReasons[i],
+		}
+		if i < missing {
+			action.Type = models.PlanReplace
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+	plan.Actions = append(plan.Actions, spawns...)
+
+	if !verify || m.Build == "" {
+		return plan, nil
+	}
+
+	keptCount := 0
+	for _, boxes := range kept {
+		keptCount += len(boxes)
+	}
+	if keptCount == 0 {
+		return plan, nil
+	}
+
+	recipe, err := manifestRecipe(m)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipe.Verify) == 0 {
+		return plan, nil
+	}
+
+	results, err := ctrl.VerifyFleet(models.BuildOptions{Recipe: recipe, FleetName: m.Name})
+	if err != nil {
+		return nil, err
+	}
+	for _, region := range regions {
+		for _, box := range kept[region] {
+			if passed, ok := results[box.Label]; ok && !passed {
+				plan.Actions = append(plan.Actions, models.PlanAction{
+					Type:   models.PlanRebuild,
+					BoxID:  box.ID,
+					Box:    box.Label,
+					Region: box.Region,
+					Reason: "verification of " + recipe.Name + " failed",
+				})
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplyFleet runs the actions of a plan: boxes are deleted first, then the
+// missing ones are spawned and the new and failing boxes are built
+func (c Controller) ApplyFleet(plan *models.FleetPlan) error {
+	m := plan.Manifest
+	ctrl := c.manifestController(m, "")
+
+	deleted := make(map[string]bool)
+	for _, action := range plan.Actions {
+		if action.Type != models.PlanDelete && action.Type != models.PlanReplace {
+			continue
+		}
+		ui.Info(fmt.Sprintf("Deleting %s (%s)", action.Box, action.Reason))
+		if err := ctrl.Service.DeleteBoxByID(action.BoxID); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", action.Box, err)
+		}
+		deleted[action.BoxID] = true
+	}
+	if len(deleted) > 0 {
+		if err := ctrl.waitDeleted(m.Name, deleted); err != nil {
+			return err
+		}
+	}
+	if plan.Expired || m.Count == 0 {
+		ctrl.deleteFleetKeys(m.Name)
+	}
+
+	before := make(map[string]bool)
+	known := make(map[string]bool)
+	for _, box := range ctrl.GetFleet(m.Name) {
+		before[box.Label] = true
+		known[box.ID] = true
+	}
+
+	spawning := 0
+	for _, action := range plan.Actions {
+		if action.Type == models.PlanSpawn {
+			spawning += action.Count
+		}
+	}
+	if spawning > 0 && len(before) == 0 {
+		if err := ctrl.newFleetKeys(m.Name); err != nil {
+			return fmt.Errorf("failed to generate the fleet SSH key: %v", err)
+		}
+	}
+
+	for _, action := range plan.Actions {
+		if action.Type != models.PlanSpawn {
+			continue
+		}
+		if action.Region != "" {
+			ui.Info(fmt.Sprintf("Spawning %d boxes in %s", action.Count, action.Region))
+		} else {
+			ui.Info(fmt.Sprintf("Spawning %d boxes", action.Count))
+		}
+		if err := c.manifestController(m, action.Region).Service.SpawnFleet(m.Name, action.Count); err != nil {
+			return fmt.Errorf("failed to spawn %d boxes: %v", action.Count, err)
+		}
+	}
+	if spawning > 0 {
+		report, err := ctrl.waitBoxesReady(m.Name, known, spawning, nil)
+		if err != nil {
+			return err
+		}
+		if len(report.Failed) > 0 {
+			return fmt.Errorf("%d boxes failed to become ready: %s", len(report.Failed), strings.Join(report.Failed, ", "))
+		}
+	}
+
+	var toBuild []string
+	for _, box := range ctrl.GetFleet(m.Name) {
+		if !before[box.Label] {
+			toBuild = append(toBuild, box.Label)
+		}
+	}
+	for _, action := range plan.Actions {
+		if action.Type == models.PlanRebuild {
+			toBuild = append(toBuild, action.Box)
+		}
+	}
+
+	if m.Build != "" && len(toBuild) > 0 {
+		recipe, err := manifestRecipe(m)
+		if err != nil {
+			return err
+		}
+		results, err := ctrl.BuildFleet(models.BuildOptions{
+			Recipe:    recipe,
+			FleetName: m.Name,
+			Boxes:     toBuild,
+		})
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, r := range results {
+			if !r.Success {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("build %s failed on %d/%d boxes", recipe.Name, failed, len(results))
+		}
+	}
+
+	return saveManifestState(plan)
+}
+
+// waitDeleted blocks until none of the ids is in the fleet anymore
+func (c Controller) waitDeleted(fleetName string, ids map[string]bool) error {
+	deadline := time.Now().Add(10 * time.Minute)
+	for {
+		fleet, err := c.Service.GetFleet(fleetName)
+		remaining := 0
+		for _, box := range fleet {
+			if ids[box.ID] {
+				remaining++
+			}
+		}
+		if err == nil && remaining == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d boxes of %s still present after deletion", remaining, fleetName)
+		}
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// saveManifestState records the first apply of a manifest to enforce its TTL.
+// The state is dropped once the fleet is gone.
+func saveManifestState(plan *models.FleetPlan) error {
+	m := plan.Manifest
+	if plan.Expired || m.Count == 0 {
+		return utils.DeleteManifestState(m.Name)
+	}
+
+	state, err := utils.ReadManifestState(m.Name)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if state == nil {
+		state = &models.ManifestState{Name: m.Name, Created: now}
+	}
+	state.Provider = m.Provider
+	state.Applied = now
+	state.Expires = time.Time{}
+	if m.TTL != "" {
+		ttl, _ := time.ParseDuration(m.TTL)
+		state.Expires = state.Created.Add(ttl)
+	}
+	return utils.SaveManifestState(state)
+}
+
+// ExpireFleets deletes the fleets of applied manifests whose TTL is over
+func ExpireFleets(configs *models.Config) {
+	states, err := utils.ListManifestStates()
+	if err != nil {
+		utils.Log.Error(err)
+		return
+	}
+
+	for _, state := range states {
+		if state.Expires.IsZero() || time.Now().Before(state.Expires) {
+			continue
+		}
+		if _, ok := configs.Providers[state.Provider]; !ok {
+			utils.Log.Errorf("[%s] Provider %s not configured, can't expire fleet", state.Name, state.Provider)
+			continue
+		}
+
+		// NewController exits when a secret can't be resolved, which would
+		// stop the daemon along with its scheduled runs
+		resolved, err := utils.ResolveProviderSecrets(configs, state.Provider)
+		if err != nil {
+			utils.Log.Errorf("[%s] Can't expire fleet: %v", state.Name, err)
+			continue
+		}
+
+		utils.Log.Infof("[%s] TTL expired, deleting fleet", state.Name)
+		ctrl := Controller{Configs: resolved}.manifestController(&models.FleetManifest{Name: state.Name, Provider: state.Provider}, "")
+		if err := ctrl.Service.DeleteFleet(state.Name); err != nil {
+			utils.Log.Errorf("[%s] %v", state.Name, err)
+			continue
+		}
+		ctrl.deleteFleetKeys(state.Name)
+		utils.DeleteManifestState(state.Name)
+	}
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/ui"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// RunWorkflowMatrix expands the workflow matrix and runs one workflow per
+// combination over the same fleet. In sequential mode every run uses the whole
+// fleet, in parallel mode the fleet is partitioned between the runs.
+func (c Controller) RunWorkflowMatrix(opts models.WorkflowOptions) ([]models.MatrixRun, error) {
+	combinations := utils.ExpandMatrix(opts.Workflow.Matrix)
+	if len(combinations) == 0 {
+		return nil, fmt.Errorf("workflow %s has an empty matrix", opts.Workflow.Name)
+	}
+
+	runs := make([]models.MatrixRun, len(combinations))
+	runOpts := make([]models.WorkflowOptions, len(combinations))
+
+	for i, combination := range combinations {
+		workflow := *opts.Workflow
+		workflow.Vars = make(map[string]string)
+		for k, v := range opts.Workflow.Vars {
+			workflow.Vars[k] = v
+		}
+		for k, v := range combination {
+			workflow.Vars[k] = v
+		}
+
+		if err := validateWorkflowVars(&workflow); err != nil {
+			return nil, fmt.Errorf("matrix %s: %w", utils.MatrixSuffix(combination), err)
+		}
+
+		suffix := utils.MatrixSuffix(combination)
+		runs[i] = models.MatrixRun{
+			Vars:   combination,
+			Suffix: suffix,
+			Output: collectOutputPath(opts.Output, suffix),
+		}
+
+		o := opts
+		o.Workflow = &workflow
+		o.Output = runs[i].Output
+		if opts.Diff.Enabled {
+			if o.Diff.Scope == "" {
+				o.Diff.Scope = utils.DefaultScope(opts.Input)
+			}
+			o.Diff.Scope += "-" + suffix
+		}
+		if opts.ChunksFolder != "" {
+			o.ChunksFolder = filepath.Join(opts.ChunksFolder, suffix)
+			utils.MakeFolder(o.ChunksFolder)
+		}
+		runOpts[i] = o
+	}
+
+	fleet := c.GetFleet(opts.FleetName)
+	if len(fleet) == 0 {
+		return nil, fmt.Errorf("fleet %s not found", opts.FleetName)
+	}
+
+	if opts.DryRun {
+		// Commands are shown expanded with the first combination
+		dryRunOpts := runOpts[0]
+		dryRunOpts.Output = opts.Output
+		_, err := c.dryRunWorkflow(dryRunOpts, fleet)
+		return runs, err
+	}
+
+	if matrixMode(opts.Workflow) == models.MatrixModeParallel {
+		if len(fleet) < len(combinations) {
+			return nil, fmt.Errorf("parallel matrix needs at least %d boxes, fleet %s has %d", len(combinations), opts.FleetName, len(fleet))
+		}
+
+		// The runs share the terminal, their spinners would overwrite each
+		// other so only the outcome of each run is printed
+		utils.Log.Infof("Running %d matrix combinations in parallel", len(runs))
+		partitions := partitionFleet(fleet, len(combinations))
+		var wg sync.WaitGroup
+		for i := range runs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				progress := ui.NewQuietWorkflowProgress(len(partitions[i]))
+				runs[i].Results, runs[i].Error = c.runWorkflowOnFleet(runOpts[i], partitions[i], progress)
+				logMatrixRun(runs[i])
+			}(i)
+		}
+		wg.Wait()
+		return runs, nil
+	}
+
+	for i := range runs {
+		// Only the last run may delete the fleet, the others still need it
+		if i < len(runs)-1 {
+			runOpts[i].Delete = false
+		}
+		utils.Log.Infof("Matrix run %d/%d: %s", i+1, len(runs), runs[i].Suffix)
+		runs[i].Results, runs[i].Error = c.runWorkflowOnFleet(runOpts[i], fleet, ui.NewWorkflowProgress(len(fleet)))
+	}
+
+	return runs, nil
+}
+
+// logMatrixRun prints the outcome of a finished matrix run
+func logMatrixRun(run models.MatrixRun) {
+	if run.Error != nil {
+		utils.Log.Warnf("Matrix run %s failed: %v", run.Suffix, run.Error)
+		return
+	}
+	success := 0
+	for _, r := range run.Results {
+		if r.Success {
+			success++
+		}
+	}
+	utils.Log.Infof("Matrix run %s: %d/%d successful", run.Suffix, success, len(run.Results))
+}
+
+func matrixMode(workflow *models.Workflow) string {
+	if workflow.MatrixMode == models.MatrixModeParallel {
+		return models.MatrixModeParallel
+	}
+	return models.MatrixModeSequential
+}
+
+// partitionFleet splits a fleet into n contiguous groups of similar size
+func partitionFleet(fleet []provider.Box, n int) [][]provider.Box {
+	if n <= 0 || len(fleet) < n {
+		return nil
+	}
+
+	partitions := make([][]provider.Box, n)
+	size := len(fleet) / n
+	rest := len(fleet) % n
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + size
+		if i < rest {
+			end++
+		}
+		partitions[i] = fleet[start:end]
+		start = end
+	}
+	return partitions
+}
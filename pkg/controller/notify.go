@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"github.com/FleexSecurity/fleex/pkg/notify"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// sendNotification delivers an event to the configured notifications. Errors
+// are only logged so a broken webhook never interrupts a scan.
+func (c Controller) sendNotification(event notify.Event) {
+	if len(c.Configs.Notifications) == 0 {
+		return
+	}
+	if err := notify.Send(c.Configs.Notifications, event); err != nil {
+		utils.Log.Warn("Failed to send notification: ", err)
+	}
+}
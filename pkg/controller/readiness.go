@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	p "github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/sshutils"
+	"github.com/FleexSecurity/fleex/pkg/ui"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// defaultReadyTimeout is how long a new box may take to become ready when the
+// settings don't say otherwise
+const defaultReadyTimeout = 10 * time.Minute
+
+func (c Controller) readyTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(c.Configs.Settings.ReadyTimeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return defaultReadyTimeout
+}
+
+// checkBoxReady waits until a running box accepts SSH with our key and
+// cloud-init has finished, or until deadline
+func (c Controller) checkBoxReady(box p.Box, deadline time.Time, onPhase func(phase string)) error {
+	providerInfo := c.Configs.Providers[c.Configs.Settings.Provider]
+	addr := box.IP + ":" + strconv.Itoa(providerInfo.Port)
+
+	onPhase(ui.PhaseSSH)
+	var conn *sshutils.Connection
+	for {
+		var err error
+		conn, err = c.dialer.Connect(addr, providerInfo.Username, c.Configs.SSHKeys.PrivateFile)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("SSH not reachable: %v", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+	defer conn.Close()
+
+	onPhase(ui.PhaseCloudInit)
+	done := make(chan error, 1)
+	go func() {
+		exitCode, err := conn.RunLines("cloud-init status --wait", func(string, bool) {})
+		if err == nil && exitCode != 0 && exitCode != 127 {
+			// cloud-init is done but some modules failed, the box is usable
+			utils.Log.Warnf("%s: cloud-init finished with status %d", box.Label, exitCode)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Until(deadline)):
+		return fmt.Errorf("cloud-init still running")
+	}
+}
+
+// defaultReplaceRetries is how many times a box may be replaced when the
+// settings don't say otherwise
+const defaultReplaceRetries = 2
+
+func (c Controller) replaceRetries() int {
+	if c.Configs.Settings.ReplaceRetries > 0 {
+		return c.Configs.Settings.ReplaceRetries
+	}
+	return defaultReplaceRetries
+}
+
+// replaceBoxes deletes boxes and spawns new boxes with the same labels once
+// the old ones are gone, as some providers require unique labels
+func (c Controller) replaceBoxes(fleetName string, boxes []p.Box) error {
+	ids := make(map[string]bool)
+	labels := make([]string, len(boxes))
+	for i, box := range boxes {
+		if err := c.Service.DeleteBoxByID(box.ID); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", box.Label, err)
+		}
+		ids[box.ID] = true
+		labels[i] = box.Label
+	}
+
+	if err := c.waitDeleted(fleetName, ids); err != nil {
+		return err
+	}
+	return c.Service.SpawnBoxes(fleetName, labels)
+}
+
+// boxWait tracks a new box until it is ready
+type boxWait struct {
+	box      p.Box
+	deadline time.Time
+	checking bool
+}
+
+type readyResult struct {
+	box p.Box
+	err error
+}
+
+type replaceResult struct {
+	label string
+	err   error
+}
+
+// readyReport lists the outcome of waiting for new boxes
+type readyReport struct {
+	Ready []p.Box
+	// Replaced are the labels whose box was replaced at least once
+	Replaced []string
+	// Failed are the labels given up after the replace retries
+	Failed []string
+}
+
+// waitBoxesReady waits until count boxes of the fleet that are not in known
+// are ready: running according to the provider, reachable over SSH and done
+// with cloud-init. A box not ready within the ready timeout is deleted and
+// replaced by a box with the same label, up to the replace retries.
+func (c Controller) waitBoxesReady(fleetName string, known map[string]bool, count int, progress *ui.SpawnProgress) (readyReport, error) {
+	c = c.withFleetKeys(fleetName)
+	providerId := GetProvider(c.Configs.Settings.Provider)
+	timeout := c.readyTimeout()
+	retries := c.replaceRetries()
+	deadline := time.Now().Add(time.Duration(retries+2) * timeout)
+
+	setPhase := func(label, phase string) {
+		if progress != nil {
+			progress.UpdateBoxPhase(label, phase)
+		}
+	}
+
+	ignored := make(map[string]bool)
+	for id := range known {
+		ignored[id] = true
+	}
+	waiting := make(map[string]*boxWait)
+	// Buffered for every box that may be checked or replaced
+	results := make(chan readyResult, (retries+1)*count)
+	replaced := make(chan replaceResult, retries*count)
+	attempts := make(map[string]int)
+	var report readyReport
+	expected := count
+
+	replace := func(box p.Box, reason string) {
+		ignored[box.ID] = true
+		delete(waiting, box.ID)
+		if progress != nil {
+			progress.BoxReplaced(box.Label, reason)
+		} else {
+			utils.Log.Warnf("%s not ready: %s", box.Label, reason)
+		}
+
+		if attempts[box.Label] >= retries {
+			utils.Log.Errorf("%s still not ready after %d replacements, giving up", box.Label, retries)
+			if err := c.Service.DeleteBoxByID(box.ID); err != nil {
+				utils.Log.Errorf("Failed to delete %s: %v", box.Label, err)
+			}
+			report.Failed = append(report.Failed, box.Label)
+			expected--
+			return
+		}
+		if attempts[box.Label] == 0 {
+			report.Replaced = append(report.Replaced, box.Label)
+		}
+		attempts[box.Label]++
+		go func() {
+			replaced <- replaceResult{label: box.Label, err: c.replaceBoxes(fleetName, []p.Box{box})}
+		}()
+	}
+
+	for len(report.Ready) < expected && time.Now().Before(deadline) {
+		fleet, err := c.Service.GetFleet(fleetName)
+		if err == nil {
+			for _, box := range fleet {
+				if ignored[box.ID] {
+					continue
+				}
+				w, ok := waiting[box.ID]
+				if !ok {
+					w = &boxWait{deadline: time.Now().Add(timeout)}
+					waiting[box.ID] = w
+					setPhase(box.Label, ui.PhaseProvisioning)
+				}
+				if w.checking {
+					continue
+				}
+				w.box = box
+				if isBoxRunning(providerId, box.Status) && box.IP != "" {
+					w.checking = true
+					go func(box p.Box, deadline time.Time) {
+						err := c.checkBoxReady(box, deadline, func(phase string) {
+							setPhase(box.Label, phase)
+						})
+						results <- readyResult{box: box, err: err}
+					}(box, w.deadline)
+				}
+			}
+		}
+
+		for _, w := range waiting {
+			if !w.checking && time.Now().After(w.deadline) {
+				replace(w.box, fmt.Sprintf("not running after %v", timeout))
+			}
+		}
+
+		timer := time.After(5 * time.Second)
+	collect:
+		for {
+			select {
+			case r := <-results:
+				if r.err != nil {
+					replace(r.box, r.err.Error())
+					continue
+				}
+				ignored[r.box.ID] = true
+				delete(waiting, r.box.ID)
+				report.Ready = append(report.Ready, r.box)
+				setPhase(r.box.Label, ui.PhaseReady)
+				if len(report.Ready) >= expected {
+					break collect
+				}
+			case r := <-replaced:
+				if r.err != nil {
+					utils.Log.Errorf("Failed to replace %s: %v", r.label, r.err)
+					report.Failed = append(report.Failed, r.label)
+					expected--
+				}
+			case <-timer:
+				break collect
+			}
+		}
+	}
+
+	if len(report.Ready) < count {
+		return report, fmt.Errorf("only %d/%d boxes of %s became ready", len(report.Ready), count, fleetName)
+	}
+	return report, nil
+}
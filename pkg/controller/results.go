@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/notify"
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// completeRun stores a finished run and notifies its completion
+func (c Controller) completeRun(record *models.RunRecord, diff models.DiffOptions) {
+	diffResult := c.storeRun(record, diff)
+
+	successful := 0
+	for _, r := range record.Results {
+		if r.Success {
+			successful++
+		}
+	}
+
+	event := notify.Event{
+		Type:    notify.EventScanComplete,
+		Title:   fmt.Sprintf("Scan %s on %s complete", record.Workflow, record.Scope),
+		Message: fmt.Sprintf("%d/%d boxes successful, %d lines in %s", successful, len(record.Results), record.Lines, record.Output),
+		Fields: map[string]string{
+			"run":      record.ID,
+			"fleet":    record.Fleet.Name,
+			"duration": record.Finished.Sub(record.Started).Round(time.Second).String(),
+		},
+	}
+	if diffResult != nil {
+		event.Fields["new"] = strconv.Itoa(len(diffResult.New))
+		event.Fields["removed"] = strconv.Itoa(len(diffResult.Removed))
+	}
+	c.sendNotification(event)
+}
+
+// storeRun saves a finished run in the result store and, in diff mode, writes
+// the new, removed and unchanged sets against the previous run of the same
+// workflow and scope. Failures are logged, the run itself already succeeded.
+func (c Controller) storeRun(record *models.RunRecord, diff models.DiffOptions) *models.DiffResult {
+	record.Scope = diff.Scope
+	if record.Scope == "" {
+		record.Scope = utils.DefaultScope(record.Input)
+	}
+	record.Provider = c.Configs.Settings.Provider
+	record.Finished = time.Now()
+	record.Lines = utils.CountLines(record.Output)
+
+	var previous *models.RunRecord
+	if diff.Enabled {
+		var err error
+		previous, err = utils.PreviousRunRecord(record.Workflow, record.Scope, record.Started)
+		if err != nil {
+			utils.Log.Warn("Failed to read the previous run: ", err)
+		}
+	}
+
+	if err := utils.SaveRunRecord(record, record.Output); err != nil {
+		utils.Log.Warn("Failed to store run: ", err)
+		return nil
+	}
+	utils.Log.Info("Run stored as ", record.ID)
+
+	if !diff.Enabled {
+		return nil
+	}
+	if previous == nil {
+		utils.Log.Infof("No previous run of %s on scope %s to compare with", record.Workflow, record.Scope)
+		return nil
+	}
+
+	result, err := diffRun(previous, record.Output, diff.Key)
+	if err != nil {
+		utils.Log.Warn("Failed to diff against the previous run: ", err)
+		return nil
+	}
+	return result
+}
+
+func diffRun(previous *models.RunRecord, output, key string) (*models.DiffResult, error) {
+	previousOutput, err := utils.RunOutputPath(previous.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := utils.DiffFiles(previousOutput, output, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.WriteDiff(result, output); err != nil {
+		return nil, err
+	}
+
+	utils.Log.Infof("Diff against %s: %d new, %d removed, %d unchanged (%s)",
+		previous.ID, len(result.New), len(result.Removed), len(result.Unchanged), utils.DiffOutputPath(output, "new"))
+	return &result, nil
+}
+
+func newRunFleet(name string, fleet []provider.Box) models.RunFleet {
+	runFleet := models.RunFleet{Name: name}
+	for _, box := range fleet {
+		runFleet.Boxes = append(runFleet.Boxes, models.RunBox{
+			ID:    box.ID,
+			Label: box.Label,
+			IP:    box.IP,
+		})
+	}
+	return runFleet
+}
+
+// runBoxResults converts workflow results into their stored form. Step output
+// is only kept for failed steps.
+func runBoxResults(results []models.WorkflowResult) []models.RunBoxResult {
+	var boxResults []models.RunBoxResult
+	for _, r := range results {
+		boxResult := models.RunBoxResult{
+			Box:     r.BoxName,
+			Success: r.Success,
+		}
+		if r.Error != nil {
+			boxResult.Error = r.Error.Error()
+		}
+		for _, step := range r.StepResults {
+			stepResult := models.RunStepResult{
+				Name:     step.StepName,
+				Success:  step.Success,
+				Duration: step.Duration,
+			}
+			if !step.Success {
+				stepResult.Output = step.Output
+			}
+			boxResult.Steps = append(boxResult.Steps, stepResult)
+		}
+		boxResults = append(boxResults, boxResult)
+	}
+	return boxResults
+}
+
+// boxResultSet collects the results of boxes running concurrently
+type boxResultSet struct {
+	mu      sync.Mutex
+	results []models.RunBoxResult
+}
+
+func (s *boxResultSet) add(box string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := models.RunBoxResult{Box: box, Success: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	s.results = append(s.results, result)
+}
+
+// scanName is the name under which a command scan is stored
+func scanName(module *models.Module) string {
+	if module.Name != "" {
+		return module.Name
+	}
+	return "scan"
+}
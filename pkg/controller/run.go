@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/ui"
+)
+
+// RunCommand runs a command on every box of the fleet, or on a single box if
+// the name matches one exactly, printing each output line prefixed with the
+// box label
+func (c Controller) RunCommand(opts models.RunOptions) ([]models.RunResult, error) {
+	fleet := c.GetFleet(opts.FleetName)
+	for _, box := range fleet {
+		if box.Label == opts.FleetName {
+			fleet = []provider.Box{box}
+			break
+		}
+	}
+	if len(fleet) == 0 {
+		return nil, fmt.Errorf("fleet %s not found", opts.FleetName)
+	}
+
+	if opts.OutputDir != "" {
+		if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	parallel := opts.Parallel
+	if parallel <= 0 || parallel > len(fleet) {
+		parallel = len(fleet)
+	}
+
+	fleetChan := make(chan provider.Box, len(fleet))
+	resultsChan := make(chan models.RunResult, len(fleet))
+	var wg sync.WaitGroup
+
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for box := range fleetChan {
+				resultsChan <- c.runCommandOnBox(box, opts)
+			}
+		}()
+	}
+
+	for _, box := range fleet {
+		fleetChan <- box
+	}
+	close(fleetChan)
+
+	go func() {
+		wg.Wait()
+		close(resultsChan)
+	}()
+
+	results := make([]models.RunResult, 0, len(fleet))
+	for result := range resultsChan {
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (c Controller) runCommandOnBox(box provider.Box, opts models.RunOptions) models.RunResult {
+	start := time.Now()
+	result := models.RunResult{BoxName: box.Label, ExitCode: -1}
+
+	var out *os.File
+	if opts.OutputDir != "" {
+		result.OutputFile = filepath.Join(opts.OutputDir, box.Label+".txt")
+		file, err := os.Create(result.OutputFile)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		defer file.Close()
+		out = file
+	}
+
+	port, username, keyPath := c.runTarget(box, opts)
+	conn, err := c.connectWithRetry(box.IP+":"+strconv.Itoa(port), username, keyPath)
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(start)
+		return result
+	}
+	defer conn.Close()
+
+	var mu sync.Mutex
+	result.ExitCode, result.Error = conn.RunLines(opts.Command, func(line string, stderr bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		ui.BoxOutput(box.Label, line, stderr)
+		if out != nil {
+			fmt.Fprintln(out, line)
+		}
+	})
+	result.Duration = time.Since(start)
+	return result
+}
+
+// runTarget returns the port, username and key to reach a box: those of its
+// custom VM if it is one, else those of the provider
+func (c Controller) runTarget(box provider.Box, opts models.RunOptions) (int, string, string) {
+	providerInfo := c.Configs.Providers[c.Configs.Settings.Provider]
+	port, username, keyPath := providerInfo.Port, providerInfo.Username, boxKeyPath(box, opts.KeyPath)
+	if customVM, ok := c.Configs.CustomVMByID(box.ID); ok {
+		port, username = customVM.SSHPort, customVM.Username
+		if customVM.KeyPath != "" {
+			keyPath = customVM.KeyPath
+		}
+	}
+
+	if opts.Port > 0 {
+		port = opts.Port
+	}
+	if opts.Username != "" {
+		username = opts.Username
+	}
+	return port, username, keyPath
+}
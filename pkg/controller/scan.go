@@ -1,10 +1,7 @@
 package controller
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -12,8 +9,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/hnakamur/go-scp"
-
 	"github.com/FleexSecurity/fleex/pkg/models"
 	p "github.com/FleexSecurity/fleex/pkg/provider"
 	"github.com/FleexSecurity/fleex/pkg/sshutils"
@@ -27,12 +22,12 @@ const (
 
 // connectWithRetry attempts SSH connection with retries for cases where droplets
 // are still booting and SSH isn't ready yet
-func connectWithRetry(addr, username, privateKey string) (*sshutils.Connection, error) {
+func (c Controller) connectWithRetry(addr, username, privateKey string) (*sshutils.Connection, error) {
 	var conn *sshutils.Connection
 	var err error
 
 	for attempt := 1; attempt <= sshMaxRetries; attempt++ {
-		conn, err = sshutils.Connect(addr, username, privateKey)
+		conn, err = c.dialer.Connect(addr, username, privateKey)
 		if err == nil {
 			return conn, nil
 		}
@@ -46,39 +41,6 @@ func connectWithRetry(addr, username, privateKey string) (*sshutils.Connection,
 	return nil, fmt.Errorf("SSH connection to %s failed after %d attempts: %w", addr, sshMaxRetries, err)
 }
 
-func lineCounter(r io.Reader) (int, error) {
-	buf := make([]byte, 32*1024)
-	count := 0
-	lineSep := []byte{'\n'}
-
-	for {
-		c, err := r.Read(buf)
-		count += bytes.Count(buf[:c], lineSep)
-
-		switch {
-		case err == io.EOF:
-			return count, nil
-
-		case err != nil:
-			return count, err
-		}
-	}
-}
-
-func GetLine(filename string, names chan string, readerr chan error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		names <- scanner.Text()
-	}
-	readerr <- scanner.Err()
-}
-
 func ReplaceCommandVars(command string, vars map[string]string) (string, error) {
 	if _, ok := vars["INPUT"]; !ok {
 		return "", fmt.Errorf("missing 'INPUT' variable")
@@ -110,9 +72,10 @@ func ReplaceVerticalCommandVars(command string, vars map[string]string) (string,
 var privateSshKeyStr string
 
 // Start runs a scan
-func (c Controller) Start(fleetName, command string, delete bool, input, outputPath1, chunksFolder string, module *models.Module) {
+func (c Controller) Start(fleetName, command string, delete bool, input, outputPath1, chunksFolder string, module *models.Module, diff models.DiffOptions, stream models.StreamOptions) {
 	var isFolderOut bool
 	start := time.Now()
+	c = c.withFleetKeys(fleetName)
 	privateSshKeyStr = c.Configs.SSHKeys.PrivateFile
 	provider := c.Configs.Settings.Provider
 	providerId := GetProvider(provider)
@@ -151,6 +114,12 @@ func (c Controller) Start(fleetName, command string, delete bool, input, outputP
 	utils.MakeFolder(tempFolderFiles)
 	utils.Log.Info("Scan started!")
 
+	findings, err := newFindingStream(stream)
+	if err != nil {
+		utils.Log.Fatal(err)
+	}
+	defer findings.Close()
+
 	// Input file to string
 
 	fleet := c.GetFleet(fleetName)
@@ -161,90 +130,31 @@ func (c Controller) Start(fleetName, command string, delete bool, input, outputP
 	// Send additional vars files (excluding "INPUT" and "OUTPUT") via SCP
 	for key, value := range module.Vars {
 		if key != "INPUT" && key != "OUTPUT" && isFile(value) {
-			newFileName := "/tmp/fleex-" + timeStamp + "-chunk-file-" + value
+			newFileName, err := remoteVarFile(value)
+			if err != nil {
+				utils.Log.Fatal(err)
+			}
 			module.Vars[key] = newFileName
-			sendFileToFleet(value, newFileName, fleet, port, username, privateSshKeyStr)
+			if err := c.sendFileToFleet(value, newFileName, fleet, port, username, privateSshKeyStr); err != nil {
+				utils.Log.Fatal("Failed to send file: ", err)
+			}
 		}
 	}
 
-	// First get lines count
-	file, err := os.Open(input)
-
-	if err != nil {
-		utils.Log.Fatal(err)
-	}
-
-	linesCount, err := lineCounter(file)
+	utils.Log.Debug("Fleet count: ", len(fleet))
 
+	// Only use the boxes that get lines (no point sending empty chunks)
+	fleet, chunks, err := shardToFleet(input, tempFolderInput, fleet, module.Shard, module.CompressChunks)
 	if err != nil {
 		utils.Log.Fatal(err)
 	}
 
-	utils.Log.Debug("Fleet count: ", len(fleet))
-
-	// Only use as many boxes as we have lines (no point sending empty chunks)
-	activeFleetSize := len(fleet)
-	if linesCount < activeFleetSize {
-		activeFleetSize = linesCount
-	}
-	if activeFleetSize == 0 {
-		utils.Log.Fatal("Input file is empty, nothing to scan")
-	}
-	if activeFleetSize < len(fleet) {
-		utils.Log.Infof("Input has %d lines, using %d of %d available boxes", linesCount, activeFleetSize, len(fleet))
-	}
-
-	// Only use the boxes we need
-	fleet = fleet[:activeFleetSize]
-
-	linesPerChunk := linesCount / len(fleet)
-	linesPerChunkRest := linesCount % len(fleet)
-
-	names := make(chan string)
-	readerr := make(chan error)
-
-	go GetLine(input, names, readerr)
-	counter := 1
-	asd := []string{}
-
-	// Use fleet box labels for chunk names so they match during SCP
-	x := 0
-
-loop:
-	for {
-		select {
-		case name := <-names:
-			// Process each line
-			asd = append(asd, name)
-
-			re := 0
-
-			if linesPerChunkRest > 0 {
-				re = 1
-			}
-			if counter%(linesPerChunk+re) == 0 {
-				utils.StringToFile(filepath.Join(tempFolderInput, "chunk-"+fleet[x].Label), strings.Join(asd[0:counter], "\n")+"\n")
-				asd = nil
-				x++
-				counter = 0
-				linesPerChunkRest--
-
-			}
-			counter++
-
-		case err := <-readerr:
-			if err != nil {
-				utils.Log.Fatal(err)
-			}
-			break loop
-		}
-	}
-
 	utils.Log.Debug("Generated file chunks")
 
 	fleetNames := make(chan *p.Box, len(fleet))
 	processGroup := new(sync.WaitGroup)
 	processGroup.Add(len(fleet))
+	boxResults := &boxResultSet{}
 
 	for i := 0; i < len(fleet); i++ {
 		go func() {
@@ -255,12 +165,16 @@ loop:
 				}
 				boxName := l.Label
 
-				conn, err := connectWithRetry(l.IP+":"+strconv.Itoa(port), username, privateSshKeyStr)
+				conn, err := c.connectWithRetry(l.IP+":"+strconv.Itoa(port), username, privateSshKeyStr)
+				if err != nil {
+					utils.Log.Fatal(err)
+				}
+				transfer, err := c.newTransferer(conn, *l)
 				if err != nil {
 					utils.Log.Fatal(err)
 				}
 				// Send input file via SCP
-				err = scp.NewSCP(conn.Client).SendFile(filepath.Join(tempFolderInput, "chunk-"+boxName), "/tmp/fleex-"+timeStamp+"-chunk-"+boxName)
+				err = sendChunk(conn, transfer, chunks[boxName], "/tmp/fleex-"+timeStamp+"-chunk-"+boxName)
 				if err != nil {
 					utils.Log.Fatal("Failed to send file: ", err)
 				}
@@ -280,15 +194,28 @@ loop:
 					utils.Log.Fatal(err)
 				}
 
-				sshutils.RunCommand(finalCommand, l.IP, port, username, privateSshKeyStr)
+				stopStream := func() {}
+				if findings != nil {
+					stopStream = findings.tail(conn, boxName, chunkOutputFile)
+				}
+
+				c.dialer.RunCommand(finalCommand, l.IP, port, username, privateSshKeyStr)
 
-				err = scp.NewSCP(conn.Client).ReceiveFile(chunkOutputFile, filepath.Join(tempFolder, "chunk-out-"+boxName))
+				err = transfer.Download(chunkOutputFile, filepath.Join(tempFolder, "chunk-out-"+boxName))
+				stopStream()
+				if findings != nil {
+					findings.addFile(boxName, filepath.Join(tempFolder, "chunk-out-"+boxName))
+				}
+				transfer.Close()
 				if err != nil {
 					utils.Log.Warnf("%s: no output received (remote file may not exist)", boxName)
+					boxResults.add(boxName, fmt.Errorf("no output received: %w", err))
+				} else {
+					boxResults.add(boxName, nil)
 				}
 
 				// Remove input chunk file from remote box to save space
-				sshutils.RunCommand("sudo rm -rf "+chunkInputFile+" "+chunkOutputFile, l.IP, port, username, privateSshKeyStr)
+				c.dialer.RunCommand("sudo rm -rf "+chunkInputFile+" "+chunkOutputFile, l.IP, port, username, privateSshKeyStr)
 
 				if delete {
 					// TODO: Not the best way to delete a box, if this program crashes/is stopped
@@ -309,6 +236,10 @@ loop:
 
 	close(fleetNames)
 	processGroup.Wait()
+	findings.Close()
+	if delete {
+		c.deleteFleetKeys(fleetName)
+	}
 
 	// Scan done, process results
 	duration := time.Since(start)
@@ -322,6 +253,18 @@ loop:
 		utils.RunCommand("cat "+filepath.Join(tempFolder, "chunk-out-*")+" > "+outputPath, true)
 	}
 
+	c.completeRun(&models.RunRecord{
+		Kind:     models.RunKindScan,
+		Workflow: scanName(module),
+		Command:  command,
+		Input:    input,
+		Output:   outputPath,
+		Vars:     module.Vars,
+		Fleet:    newRunFleet(fleetName, fleet),
+		Started:  start,
+		Results:  boxResults.results,
+	}, diff)
+
 	if chunksFolder == "" {
 		//utils.RunCommand("rm -rf " + filepath.Join(tempFolder, "chunk-out-*"))
 		os.RemoveAll(tempFolder)
@@ -362,24 +305,40 @@ func isFile(path string) bool {
 	return !info.IsDir()
 }
 
-func sendFileToFleet(filePath, destinationPath string, fleet []p.Box, port int, username, privateKey string) error {
+func (c Controller) sendFileToFleet(filePath, destinationPath string, fleet []p.Box, port int, username, privateKey string) error {
 	for _, box := range fleet {
-		conn, err := connectWithRetry(box.IP+":"+strconv.Itoa(port), username, privateKey)
+		conn, err := c.connectWithRetry(box.IP+":"+strconv.Itoa(port), username, privateKey)
 		if err != nil {
 			return err
 		}
 
-		err = scp.NewSCP(conn.Client).SendFile(filePath, destinationPath)
+		transfer, err := c.newTransferer(conn, box)
+		if err == nil {
+			err = transfer.Upload(filePath, destinationPath)
+			transfer.Close()
+		}
+		conn.Close()
 		if err != nil {
-			return err
+			return fmt.Errorf("[%s] %w", box.Label, err)
 		}
 	}
 	return nil
 }
 
-func (c Controller) VerticalStart(fleetName, command string, delete bool, outputPath1, chunksFolder string, module *models.Module, splitVar string) {
+// remoteVarFile names the remote copy of a file var after its content, so
+// boxes that already hold it can skip the upload on the next run
+func remoteVarFile(path string) (string, error) {
+	hash, err := sshutils.FileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	return "/tmp/fleex-file-" + hash[:16] + "-" + filepath.Base(path), nil
+}
+
+func (c Controller) VerticalStart(fleetName, command string, delete bool, outputPath1, chunksFolder string, module *models.Module, splitVar string, diff models.DiffOptions, stream models.StreamOptions) {
 	var isFolderOut bool
 	start := time.Now()
+	c = c.withFleetKeys(fleetName)
 	privateSshKeyStr = c.Configs.SSHKeys.PrivateFile
 	provider := c.Configs.Settings.Provider
 	providerId := GetProvider(provider)
@@ -418,6 +377,12 @@ func (c Controller) VerticalStart(fleetName, command string, delete bool, output
 	utils.MakeFolder(tempFolderFiles)
 	utils.Log.Info("Vertical scan started!")
 
+	findings, err := newFindingStream(stream)
+	if err != nil {
+		utils.Log.Fatal(err)
+	}
+	defer findings.Close()
+
 	fleet := c.GetFleet(fleetName)
 	if len(fleet) < 1 {
 		utils.Log.Fatal("No fleet found")
@@ -425,86 +390,31 @@ func (c Controller) VerticalStart(fleetName, command string, delete bool, output
 
 	for key, value := range module.Vars {
 		if key != splitVar && key != "OUTPUT" && isFile(value) {
-			newFileName := "/tmp/fleex-" + timeStamp + "-chunk-file-" + filepath.Base(value)
+			newFileName, err := remoteVarFile(value)
+			if err != nil {
+				utils.Log.Fatal(err)
+			}
 			module.Vars[key] = newFileName
-			sendFileToFleet(value, newFileName, fleet, port, username, privateSshKeyStr)
+			if err := c.sendFileToFleet(value, newFileName, fleet, port, username, privateSshKeyStr); err != nil {
+				utils.Log.Fatal("Failed to send file: ", err)
+			}
 		}
 	}
 
-	file, err := os.Open(splitFilePath)
-	if err != nil {
-		utils.Log.Fatal(err)
-	}
+	utils.Log.Debug("Fleet count: ", len(fleet))
 
-	linesCount, err := lineCounter(file)
+	// Only use the boxes that get lines (no point sending empty chunks)
+	fleet, chunks, err := shardToFleet(splitFilePath, tempFolderInput, fleet, module.Shard, module.CompressChunks)
 	if err != nil {
 		utils.Log.Fatal(err)
 	}
 
-	utils.Log.Debug("Fleet count: ", len(fleet))
-	utils.Log.Debug("Total lines to split: ", linesCount)
-
-	// Only use as many boxes as we have lines (no point sending empty chunks)
-	activeFleetSize := len(fleet)
-	if linesCount < activeFleetSize {
-		activeFleetSize = linesCount
-	}
-	if activeFleetSize == 0 {
-		utils.Log.Fatal("Input file is empty, nothing to scan")
-	}
-	if activeFleetSize < len(fleet) {
-		utils.Log.Infof("Input has %d lines, using %d of %d available boxes", linesCount, activeFleetSize, len(fleet))
-	}
-
-	// Only use the boxes we need
-	fleet = fleet[:activeFleetSize]
-
-	linesPerChunk := linesCount / len(fleet)
-	linesPerChunkRest := linesCount % len(fleet)
-
-	names := make(chan string)
-	readerr := make(chan error)
-
-	go GetLine(splitFilePath, names, readerr)
-	counter := 1
-	asd := []string{}
-
-	x := 1
-
-loop:
-	for {
-		select {
-		case name := <-names:
-			asd = append(asd, name)
-
-			re := 0
-
-			if linesPerChunkRest > 0 {
-				re = 1
-			}
-			if counter%(linesPerChunk+re) == 0 {
-				utils.StringToFile(filepath.Join(tempFolderInput, "chunk-"+fleetName+"-"+strconv.Itoa(x)), strings.Join(asd[0:counter], "\n")+"\n")
-				asd = nil
-				x++
-				counter = 0
-				linesPerChunkRest--
-
-			}
-			counter++
-
-		case err := <-readerr:
-			if err != nil {
-				utils.Log.Fatal(err)
-			}
-			break loop
-		}
-	}
-
 	utils.Log.Debug("Generated file chunks for split variable")
 
 	fleetNames := make(chan *p.Box, len(fleet))
 	processGroup := new(sync.WaitGroup)
 	processGroup.Add(len(fleet))
+	boxResults := &boxResultSet{}
 
 	for i := 0; i < len(fleet); i++ {
 		go func() {
@@ -515,20 +425,25 @@ loop:
 				}
 				boxName := l.Label
 
-				conn, err := sshutils.Connect(l.IP+":"+strconv.Itoa(port), username, privateSshKeyStr)
+				conn, err := c.dialer.Connect(l.IP+":"+strconv.Itoa(port), username, privateSshKeyStr)
 				if err != nil {
 					utils.Log.Fatal(err)
 				}
 
-				chunkPath := filepath.Join(tempFolderInput, "chunk-"+boxName)
-				if _, err := os.Stat(chunkPath); os.IsNotExist(err) {
+				chunkPath, ok := chunks[boxName]
+				if !ok {
 					utils.Log.Debug("No chunk for box ", boxName, ", skipping")
 					processGroup.Done()
 					continue
 				}
 
+				transfer, err := c.newTransferer(conn, *l)
+				if err != nil {
+					utils.Log.Fatal(err)
+				}
+
 				remoteSplitFile := "/tmp/fleex-" + timeStamp + "-chunk-" + boxName
-				err = scp.NewSCP(conn.Client).SendFile(chunkPath, remoteSplitFile)
+				err = sendChunk(conn, transfer, chunkPath, remoteSplitFile)
 				if err != nil {
 					utils.Log.Fatal("Failed to send file: ", err)
 				}
@@ -547,18 +462,29 @@ loop:
 					utils.Log.Fatal(err)
 				}
 
-				sshutils.RunCommand(finalCommand, l.IP, port, username, privateSshKeyStr)
+				stopStream := func() {}
+				if findings != nil {
+					stopStream = findings.tail(conn, boxName, chunkOutputFile)
+				}
+
+				c.dialer.RunCommand(finalCommand, l.IP, port, username, privateSshKeyStr)
+				stopStream()
 
-				err = scp.NewSCP(conn.Client).ReceiveFile(chunkOutputFile, filepath.Join(tempFolder, "chunk-out-"+boxName))
+				err = transfer.Download(chunkOutputFile, filepath.Join(tempFolder, "chunk-out-"+boxName))
 				if err != nil {
 					os.Remove(filepath.Join(tempFolder, "chunk-out-"+boxName))
-					err := scp.NewSCP(conn.Client).ReceiveDir(chunkOutputFile, filepath.Join(tempFolder, "chunk-out-"+boxName), nil)
+					err := transfer.DownloadDir(chunkOutputFile, filepath.Join(tempFolder, "chunk-out-"+boxName), nil)
 					if err != nil {
 						utils.Log.Fatal("SEND DIR ERROR: ", err)
 					}
 				}
+				transfer.Close()
+				boxResults.add(boxName, nil)
+				if findings != nil {
+					findings.addFile(boxName, filepath.Join(tempFolder, "chunk-out-"+boxName))
+				}
 
-				sshutils.RunCommand("sudo rm -rf "+remoteSplitFile+" "+chunkOutputFile, l.IP, port, username, privateSshKeyStr)
+				c.dialer.RunCommand("sudo rm -rf "+remoteSplitFile+" "+chunkOutputFile, l.IP, port, username, privateSshKeyStr)
 
 				if delete {
 					c.DeleteBoxByID(l.ID, "", providerId)
@@ -576,6 +502,10 @@ loop:
 
 	close(fleetNames)
 	processGroup.Wait()
+	findings.Close()
+	if delete {
+		c.deleteFleetKeys(fleetName)
+	}
 
 	duration := time.Since(start)
 	utils.Log.Info("Vertical scan done! Took ", duration, ". Output file: ", outputPath)
@@ -586,6 +516,18 @@ loop:
 		utils.RunCommand("cat "+filepath.Join(tempFolder, "chunk-out-*")+" > "+outputPath, true)
 	}
 
+	c.completeRun(&models.RunRecord{
+		Kind:     models.RunKindScan,
+		Workflow: scanName(module),
+		Command:  command,
+		Input:    splitFilePath,
+		Output:   outputPath,
+		Vars:     module.Vars,
+		Fleet:    newRunFleet(fleetName, fleet),
+		Started:  start,
+		Results:  boxResults.results,
+	}, diff)
+
 	if chunksFolder == "" {
 		os.RemoveAll(tempFolder)
 	}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// RunScheduledJob spawns a fleet for the job, runs its workflow, tears the
+// fleet down and records the run in the job history. Runs of the same job
+// never overlap: if a previous run still holds the lock, the run is skipped.
+func (c Controller) RunScheduledJob(job models.ScheduledJob) models.ScheduleRun {
+	run := models.ScheduleRun{
+		Job:     job.Name,
+		Started: time.Now(),
+	}
+
+	acquired, err := utils.AcquireScheduleLock(job.Name)
+	if err != nil || !acquired {
+		run.Skipped = true
+		run.Error = "previous run still in progress"
+		if err != nil {
+			run.Error = err.Error()
+		}
+		run.Finished = time.Now()
+		c.recordScheduleRun(run, job.Retention)
+		return run
+	}
+	defer utils.ReleaseScheduleLock(job.Name)
+
+	err = c.runScheduledJob(job, &run)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	run.Success = err == nil
+	run.Finished = time.Now()
+	c.recordScheduleRun(run, job.Retention)
+
+	return run
+}
+
+func (c Controller) runScheduledJob(job models.ScheduledJob, run *models.ScheduleRun) error {
+	workflow, err := utils.ReadWorkflowFile(job.Workflow)
+	if err != nil {
+		return err
+	}
+	if workflow.Vars == nil {
+		workflow.Vars = make(map[string]string)
+	}
+	for k, v := range job.Params {
+		workflow.Vars[k] = v
+	}
+	if err := validateWorkflowVars(workflow); err != nil {
+		return err
+	}
+
+	fleetName := ScheduleFleetName(job)
+	if existing, _ := c.Service.GetFleet(fleetName); len(existing) > 0 {
+		return fmt.Errorf("fleet %s already exists, refusing to reuse it", fleetName)
+	}
+
+	runsDir, err := utils.GetScheduleRunsDir(job.Name)
+	if err != nil {
+		return err
+	}
+	outputDir := filepath.Join(runsDir, run.Started.Format("20060102-150405"))
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	run.Output = filepath.Join(outputDir, "output.txt")
+
+	if err := c.newFleetKeys(fleetName); err != nil {
+		return fmt.Errorf("failed to generate the fleet SSH key: %w", err)
+	}
+	c = c.withFleetKeys(fleetName)
+
+	utils.Log.Infof("[%s] Spawning fleet %s (%d boxes)", job.Name, fleetName, job.FleetSize)
+	if err := c.Service.SpawnFleet(fleetName, job.FleetSize); err != nil {
+		c.Service.DeleteFleet(fleetName)
+		c.deleteFleetKeys(fleetName)
+		return fmt.Errorf("spawn failed: %w", err)
+	}
+	defer func() {
+		utils.Log.Infof("[%s] Deleting fleet %s", job.Name, fleetName)
+		if err := c.Service.DeleteFleet(fleetName); err != nil {
+			utils.Log.Errorf("[%s] Failed to delete fleet %s: %v", job.Name, fleetName, err)
+			return
+		}
+		c.deleteFleetKeys(fleetName)
+	}()
+
+	if _, err := c.waitBoxesReady(fleetName, nil, job.FleetSize, nil); err != nil {
+		return err
+	}
+
+	if job.Build != "" {
+		recipe, err := utils.ReadBuildFile(job.Build)
+		if err != nil {
+			return err
+		}
+		results, err := c.BuildFleet(models.BuildOptions{
+			Recipe:    recipe,
+			FleetName: fleetName,
+			Parallel:  5,
+		})
+		if err != nil {
+			return fmt.Errorf("build failed: %w", err)
+		}
+		for _, r := range results {
+			if !r.Success {
+				return fmt.Errorf("build failed on %s: %v", r.BoxName, r.Error)
+			}
+		}
+	}
+
+	results, err := c.RunWorkflow(models.WorkflowOptions{
+		Workflow:  workflow,
+		FleetName: fleetName,
+		Input:     utils.ExpandPath(job.Input),
+		Output:    run.Output,
+		Diff: models.DiffOptions{
+			Enabled: job.Diff,
+			Key:     job.DiffKey,
+			Scope:   job.Name,
+		},
+	})
+	run.Boxes = len(results)
+	for _, r := range results {
+		if r.Success {
+			run.Successful++
+		}
+	}
+	return err
+}
+
+func isBoxRunning(providerId Provider, status string) bool {
+	switch providerId {
+	case PROVIDER_LINODE:
+		return status == "running"
+	case PROVIDER_DIGITALOCEAN, PROVIDER_VULTR:
+		return status == "active"
+	}
+	return true
+}
+
+func (c Controller) recordScheduleRun(run models.ScheduleRun, retention int) {
+	if err := utils.AppendScheduleRun(run, retention); err != nil {
+		utils.Log.Errorf("[%s] Failed to record run: %v", run.Job, err)
+	}
+}
+
+// ScheduleFleetName returns the name of the fleet used by a scheduled job
+func ScheduleFleetName(job models.ScheduledJob) string {
+	if job.FleetName != "" {
+		return job.FleetName
+	}
+	return "sched-" + strings.ToLower(job.Name)
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/sshutils"
+	"github.com/FleexSecurity/fleex/pkg/ui"
+)
+
+// SCP copies files and folders to every box, or pulls them from every box
+// into one local folder per box when opts.FromFleet is set
+func (c Controller) SCP(boxes []provider.Box, opts models.SCPOptions) error {
+	sources := opts.Sources
+	if !opts.FromFleet {
+		var err error
+		sources, err = expandLocalSources(opts.Sources)
+		if err != nil {
+			return err
+		}
+	}
+
+	progress := ui.NewTransferProgress()
+	if opts.FromFleet {
+		progress.Start(fmt.Sprintf("Downloading from %d boxes...", len(boxes)))
+	} else {
+		progress.Start(fmt.Sprintf("Uploading %d sources to %d boxes...", len(sources), len(boxes)))
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+	for _, box := range boxes {
+		wg.Add(1)
+		go func(b provider.Box) {
+			defer wg.Done()
+
+			onFile := func(name string, size int64) {
+				progress.File(b.Label, name, size)
+			}
+
+			var err error
+			if opts.FromFleet {
+				err = c.scpFromBox(b, sources, opts, onFile)
+			} else {
+				err = c.scpToBox(b, sources, opts, onFile)
+			}
+			if err != nil {
+				progress.BoxFailed(b.Label, err.Error())
+				mu.Lock()
+				failed = append(failed, b.Label)
+				mu.Unlock()
+			}
+		}(box)
+	}
+	wg.Wait()
+	progress.Done(len(boxes))
+
+	if len(failed) > 0 {
+		return fmt.Errorf("transfer failed on %d boxes: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (c Controller) scpToBox(box provider.Box, sources []string, opts models.SCPOptions, onFile sshutils.ProgressFunc) error {
+	conn, err := c.connectWithRetry(box.IP+":"+strconv.Itoa(opts.Port), opts.Username, boxKeyPath(box, opts.KeyPath))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	transfer, err := c.newTransferer(conn, box)
+	if err != nil {
+		return err
+	}
+	defer transfer.Close()
+
+	// Several sources, or a destination ending with a slash, go inside the
+	// destination folder
+	intoDir := len(sources) > 1 || strings.HasSuffix(opts.Destination, "/")
+	if intoDir {
+		if err := conn.MkdirAll(opts.Destination); err != nil {
+			return err
+		}
+	}
+
+	for _, source := range sources {
+		target := opts.Destination
+		if intoDir {
+			target = path.Join(opts.Destination, filepath.Base(source))
+		}
+
+		info, err := os.Stat(source)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = transfer.UploadDir(source, target, onFile)
+		} else {
+			err = transfer.Upload(source, target)
+			if err == nil {
+				onFile(filepath.Base(source), info.Size())
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	return nil
+}
+
+func (c Controller) scpFromBox(box provider.Box, sources []string, opts models.SCPOptions, onFile sshutils.ProgressFunc) error {
+	conn, err := c.connectWithRetry(box.IP+":"+strconv.Itoa(opts.Port), opts.Username, boxKeyPath(box, opts.KeyPath))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	transfer, err := c.newTransferer(conn, box)
+	if err != nil {
+		return err
+	}
+	defer transfer.Close()
+
+	var paths []string
+	for _, source := range sources {
+		matches, err := conn.RemoteGlob(source)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no files matching %s", strings.Join(sources, " "))
+	}
+
+	localDir := filepath.Join(opts.Destination, box.Label)
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return err
+	}
+
+	for _, remotePath := range paths {
+		target := filepath.Join(localDir, path.Base(remotePath))
+		if conn.IsRemoteDir(remotePath) {
+			err = transfer.DownloadDir(remotePath, target, onFile)
+		} else {
+			err = transfer.Download(remotePath, target)
+			if err == nil {
+				if info, statErr := os.Stat(target); statErr == nil {
+					onFile(path.Base(remotePath), info.Size())
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", remotePath, err)
+		}
+	}
+	return nil
+}
+
+// expandLocalSources resolves glob patterns in the local sources
+func expandLocalSources(sources []string) ([]string, error) {
+	var expanded []string
+	for _, source := range sources {
+		matches, err := filepath.Glob(source)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files matching %s", source)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
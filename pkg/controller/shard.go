@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/sshutils"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// shardWeights returns the weight of each box for the weighted strategy.
+// Boxes with unknown size count as one CPU.
+func shardWeights(fleet []provider.Box) []int {
+	weights := make([]int, len(fleet))
+	for i, box := range fleet {
+		weights[i] = box.CPUs
+		if weights[i] < 1 {
+			weights[i] = 1
+		}
+	}
+	return weights
+}
+
+// shardToFleet splits inputFile into one chunk-<label> file per box in
+// outputDir. It returns the boxes that received at least one line and the
+// path of their chunk.
+func shardToFleet(inputFile, outputDir string, fleet []provider.Box, strategy string, compress bool) ([]provider.Box, map[string]string, error) {
+	chunkFiles := make([]string, len(fleet))
+	for i, box := range fleet {
+		chunkFiles[i] = filepath.Join(outputDir, "chunk-"+box.Label)
+		if compress {
+			chunkFiles[i] += ".gz"
+		}
+	}
+
+	counts, err := utils.ShardFile(inputFile, chunkFiles, strategy, shardWeights(fleet), compress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var active []provider.Box
+	chunks := make(map[string]string)
+	total := 0
+	for i, count := range counts {
+		total += count
+		if count > 0 {
+			active = append(active, fleet[i])
+			chunks[fleet[i].Label] = chunkFiles[i]
+		}
+	}
+	if total == 0 {
+		return nil, nil, fmt.Errorf("input file is empty, nothing to scan")
+	}
+
+	if len(active) < len(fleet) {
+		utils.Log.Infof("Input has %d lines, using %d of %d available boxes", total, len(active), len(fleet))
+	}
+	return active, chunks, nil
+}
+
+// sendChunk uploads a chunk to remotePath, decompressing it on the box if it
+// was gzipped
+func sendChunk(conn *sshutils.Connection, transfer sshutils.Transferer, localPath, remotePath string) error {
+	if !strings.HasSuffix(localPath, ".gz") {
+		return transfer.Upload(localPath, remotePath)
+	}
+
+	if err := transfer.Upload(localPath, remotePath+".gz"); err != nil {
+		return err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if output, err := session.CombinedOutput("gunzip -f " + remotePath + ".gz"); err != nil {
+		return fmt.Errorf("failed to decompress chunk: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
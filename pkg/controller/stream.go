@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/notify"
+	"github.com/FleexSecurity/fleex/pkg/sshutils"
+	"github.com/FleexSecurity/fleex/pkg/ui"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+const streamWebhookInterval = 5 * time.Second
+
+// findingStream deduplicates the lines tailed from the boxes and forwards the
+// new ones to stdout, a local file and/or a webhook
+type findingStream struct {
+	opts  models.StreamOptions
+	match *regexp.Regexp
+
+	mu      sync.Mutex
+	seen    map[string]bool
+	file    *os.File
+	pending []string
+
+	done      chan struct{}
+	flushed   chan struct{}
+	closeOnce sync.Once
+}
+
+// newFindingStream returns nil if streaming is not enabled
+func newFindingStream(opts models.StreamOptions) (*findingStream, error) {
+	if !opts.Enabled() {
+		return nil, nil
+	}
+
+	s := &findingStream{
+		opts:    opts,
+		seen:    make(map[string]bool),
+		done:    make(chan struct{}),
+		flushed: make(chan struct{}),
+	}
+
+	if opts.Match != "" {
+		match, err := regexp.Compile(opts.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stream match: %w", err)
+		}
+		s.match = match
+	}
+
+	if opts.File != "" {
+		file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		s.file = file
+	}
+
+	go s.flushLoop()
+	return s, nil
+}
+
+func (s *findingStream) add(box, line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if s.match != nil && !s.match.MatchString(line) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen[line] {
+		return
+	}
+	s.seen[line] = true
+
+	if s.opts.Stdout {
+		ui.Finding(box, line)
+	}
+	if s.file != nil {
+		fmt.Fprintln(s.file, line)
+	}
+	if s.opts.Webhook != "" {
+		s.pending = append(s.pending, line)
+	}
+}
+
+// addFile streams the lines of a local file, e.g. the output received from a
+// box, in case the tail stopped before reading the last ones
+func (s *findingStream) addFile(box, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			s.add(box, strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// tail follows a remote output file until the returned function is called.
+// Failing to start the tail only disables streaming for that box.
+func (s *findingStream) tail(conn *sshutils.Connection, box, remoteFile string) func() {
+	stop, err := conn.Tail(remoteFile, func(line string) {
+		s.add(box, line)
+	})
+	if err != nil {
+		utils.Log.Warnf("[%s] failed to stream output: %v", box, err)
+		return func() {}
+	}
+	return stop
+}
+
+func (s *findingStream) flushLoop() {
+	defer close(s.flushed)
+
+	if s.opts.Webhook == "" {
+		<-s.done
+		return
+	}
+
+	ticker := time.NewTicker(streamWebhookInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flushWebhook()
+		case <-s.done:
+			s.flushWebhook()
+			return
+		}
+	}
+}
+
+func (s *findingStream) flushWebhook() {
+	s.mu.Lock()
+	lines := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(lines) == 0 {
+		return
+	}
+
+	err := notify.SendTo(models.Notification{Type: s.opts.WebhookType, URL: s.opts.Webhook}, notify.Event{
+		Type:    notify.EventFinding,
+		Title:   fmt.Sprintf("%d new findings", len(lines)),
+		Message: strings.Join(lines, "\n"),
+	})
+	if err != nil {
+		utils.Log.Warn("Failed to stream findings: ", err)
+	}
+}
+
+// Close flushes the pending lines and closes the output file. It is a no-op
+// on a nil or already closed stream.
+func (s *findingStream) Close() {
+	if s == nil {
+		return
+	}
+
+	s.closeOnce.Do(func() {
+		close(s.done)
+		<-s.flushed
+
+		if s.file != nil {
+			s.file.Close()
+		}
+	})
+}
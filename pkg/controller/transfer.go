@@ -0,0 +1,13 @@
+package controller
+
+import (
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/sshutils"
+)
+
+// newTransferer returns the file transferer configured for the box's
+// provider or custom VM
+func (c Controller) newTransferer(conn *sshutils.Connection, box provider.Box) (sshutils.Transferer, error) {
+	backend := c.Configs.TransferBackend(c.Configs.Settings.Provider, box.ID)
+	return conn.NewTransferer(backend, c.Configs.Settings.Transfer)
+}
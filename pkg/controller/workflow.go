@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,21 +10,17 @@ import (
 	"sync"
 	"time"
 
-	"github.com/hnakamur/go-scp"
-
 	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/notify"
 	"github.com/FleexSecurity/fleex/pkg/provider"
-	"github.com/FleexSecurity/fleex/pkg/sshutils"
 	"github.com/FleexSecurity/fleex/pkg/ui"
 	"github.com/FleexSecurity/fleex/pkg/utils"
 )
 
 func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowResult, error) {
-	start := time.Now()
-	providerName := c.Configs.Settings.Provider
-	port := c.Configs.Providers[providerName].Port
-	username := c.Configs.Providers[providerName].Username
-	privateKeyPath := c.Configs.SSHKeys.PrivateFile
+	if err := validateWorkflowVars(opts.Workflow); err != nil {
+		return nil, err
+	}
 
 	fleet := c.GetFleet(opts.FleetName)
 	if len(fleet) == 0 {
@@ -36,6 +31,17 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 		return c.dryRunWorkflow(opts, fleet)
 	}
 
+	return c.runWorkflowOnFleet(opts, fleet, ui.NewWorkflowProgress(len(fleet)))
+}
+
+// runWorkflowOnFleet runs an already validated workflow on the given boxes
+func (c Controller) runWorkflowOnFleet(opts models.WorkflowOptions, fleet []provider.Box, progress *ui.WorkflowProgress) ([]models.WorkflowResult, error) {
+	start := time.Now()
+	providerName := c.Configs.Settings.Provider
+	port := c.Configs.Providers[providerName].Port
+	username := c.Configs.Providers[providerName].Username
+	privateKeyPath := c.withFleetKeys(opts.FleetName).Configs.SSHKeys.PrivateFile
+
 	scaleMode := opts.Workflow.ScaleMode
 	if scaleMode == "" {
 		scaleMode = "horizontal"
@@ -45,7 +51,12 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 		return nil, fmt.Errorf("vertical scale-mode requires split-var to be specified")
 	}
 
-	progress := ui.NewWorkflowProgress(len(fleet))
+	stream, err := newFindingStream(opts.Stream)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
 	progress.Start(opts.Workflow.Name, len(opts.Workflow.Steps))
 
 	timeStamp := strconv.FormatInt(time.Now().UnixNano(), 10)
@@ -65,7 +76,7 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 
 	if len(opts.Workflow.Setup) > 0 {
 		progress.StartSetup()
-		err := c.runSetupCommands(fleet, opts.Workflow.Setup, port, username, privateKeyPath)
+		err := c.runSetupCommands(fleet, opts.Workflow.Setup, opts.Workflow.Vars, port, username, privateKeyPath)
 		if err != nil {
 			return nil, fmt.Errorf("setup failed: %w", err)
 		}
@@ -82,7 +93,6 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 	}
 
 	var chunkFiles []string
-	var err error
 	splitVarChunksMap := make(map[string][]string)
 
 	if scaleMode == "vertical" {
@@ -93,7 +103,7 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 		splitVarFile = utils.ExpandPath(splitVarFile)
 
 		progress.StartChunking(splitVarFile)
-		splitVarChunks, err := c.splitInputIntoChunks(splitVarFile, tempFolderInput, opts.FleetName+"-split-"+opts.Workflow.SplitVar, len(fleet))
+		splitVarChunks, err := c.splitInputIntoChunks(splitVarFile, tempFolderInput, opts.FleetName+"-split-"+opts.Workflow.SplitVar, fleet, opts.Workflow.Shard, opts.Workflow.CompressChunks)
 		if err != nil {
 			return nil, fmt.Errorf("failed to split %s: %w", opts.Workflow.SplitVar, err)
 		}
@@ -106,7 +116,7 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 		}
 	} else {
 		progress.StartChunking(opts.Input)
-		chunkFiles, err = c.splitInputIntoChunks(opts.Input, tempFolderInput, opts.FleetName, len(fleet))
+		chunkFiles, err = c.splitInputIntoChunks(opts.Input, tempFolderInput, opts.FleetName, fleet, opts.Workflow.Shard, opts.Workflow.CompressChunks)
 		if err != nil {
 			return nil, fmt.Errorf("failed to split input: %w", err)
 		}
@@ -121,7 +131,7 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 					return nil, fmt.Errorf("step '%s' split-var '%s' not found in workflow vars", step.Name, step.SplitVar)
 				}
 				splitVarFile = utils.ExpandPath(splitVarFile)
-				splitVarChunks, err := c.splitInputIntoChunks(splitVarFile, tempFolderInput, opts.FleetName+"-split-"+step.SplitVar, len(fleet))
+				splitVarChunks, err := c.splitInputIntoChunks(splitVarFile, tempFolderInput, opts.FleetName+"-split-"+step.SplitVar, fleet, opts.Workflow.Shard, opts.Workflow.CompressChunks)
 				if err != nil {
 					return nil, fmt.Errorf("failed to split %s for step %s: %w", step.SplitVar, step.Name, err)
 				}
@@ -141,7 +151,7 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 			defer wg.Done()
 			for item := range fleetChan {
 				progress.StartBox(item.box.Label, len(opts.Workflow.Steps))
-				result := c.runWorkflowOnBox(item, opts, port, username, privateKeyPath, tempFolder, timeStamp, progress)
+				result := c.runWorkflowOnBox(item, opts, port, username, privateKeyPath, tempFolder, timeStamp, progress, stream)
 				if result.Success {
 					progress.BoxSuccess(item.box.Label)
 				} else {
@@ -150,6 +160,15 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 						errMsg = result.Error.Error()
 					}
 					progress.BoxFailed(item.box.Label, errMsg)
+					c.sendNotification(notify.Event{
+						Type:    notify.EventBoxFailed,
+						Title:   fmt.Sprintf("Box %s failed in workflow %s", item.box.Label, opts.Workflow.Name),
+						Message: errMsg,
+						Fields: map[string]string{
+							"fleet": opts.FleetName,
+							"ip":    item.box.IP,
+						},
+					})
 				}
 				resultsChan <- result
 			}
@@ -191,6 +210,8 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 		idx++
 	}
 
+	stream.Close()
+
 	progress.StartAggregating()
 	err = c.aggregateResults(tempFolderOutput, opts.Output, opts.Workflow.Output)
 	if err != nil {
@@ -198,6 +219,31 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 	}
 	progress.AggregatingDone(opts.Output)
 
+	for i, step := range opts.Workflow.Steps {
+		if !step.Collect {
+			continue
+		}
+		key := collectKey(step, i)
+		collectOutput := collectOutputPath(opts.Output, key)
+		err = c.aggregateResults(filepath.Join(tempFolder, "collect", key), collectOutput, opts.Workflow.Output)
+		if err != nil {
+			utils.Log.Warnf("Failed to aggregate collected output of step %s: %v", step.Name, err)
+			continue
+		}
+		utils.Log.Info("Collected output of step ", step.Name, " saved to: ", collectOutput)
+	}
+
+	c.completeRun(&models.RunRecord{
+		Kind:     models.RunKindWorkflow,
+		Workflow: opts.Workflow.Name,
+		Input:    opts.Input,
+		Output:   opts.Output,
+		Vars:     opts.Workflow.Vars,
+		Fleet:    newRunFleet(opts.FleetName, fleet),
+		Started:  start,
+		Results:  runBoxResults(results),
+	}, opts.Diff)
+
 	if opts.Delete {
 		for _, box := range fleet {
 			providerId := GetProvider(providerName)
@@ -215,6 +261,34 @@ func (c Controller) RunWorkflow(opts models.WorkflowOptions) ([]models.WorkflowR
 	return results, nil
 }
 
+// validateWorkflowVars applies the workflow params to its vars and makes sure
+// every {vars.X} placeholder can be resolved before anything runs on the fleet
+func validateWorkflowVars(workflow *models.Workflow) error {
+	if workflow.Vars == nil {
+		workflow.Vars = make(map[string]string)
+	}
+
+	if err := utils.ApplyParams(workflow.Params, workflow.Vars); err != nil {
+		return fmt.Errorf("invalid workflow params: %w", err)
+	}
+
+	var texts []string
+	for _, step := range workflow.Steps {
+		texts = append(texts, step.Command)
+	}
+	for _, file := range workflow.Files {
+		texts = append(texts, file.Source, file.Destination)
+	}
+	texts = append(texts, workflow.Setup...)
+
+	for _, text := range texts {
+		if _, err := utils.ReplaceWorkflowVars(text, workflow.Vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type boxWithChunk struct {
 	box              *provider.Box
 	chunkFile        string
@@ -237,11 +311,18 @@ func (c Controller) dryRunWorkflow(opts models.WorkflowOptions, fleet []provider
 	if scaleMode == "vertical" {
 		fmt.Printf("Split variable: %s\n", opts.Workflow.SplitVar)
 	}
+	if opts.Workflow.Shard != "" {
+		fmt.Printf("Shard strategy: %s\n", opts.Workflow.Shard)
+	}
+	if len(opts.Workflow.Included) > 0 {
+		fmt.Printf("Included: %s\n", strings.Join(opts.Workflow.Included, ", "))
+	}
 	fmt.Println()
 
 	if len(opts.Workflow.Setup) > 0 {
 		fmt.Println("Setup commands (run on all boxes):")
 		for _, cmd := range opts.Workflow.Setup {
+			cmd, _ = utils.ReplaceWorkflowVars(cmd, opts.Workflow.Vars)
 			fmt.Printf("  $ %s\n", cmd)
 		}
 		fmt.Println()
@@ -250,9 +331,14 @@ func (c Controller) dryRunWorkflow(opts models.WorkflowOptions, fleet []provider
 	if len(opts.Workflow.Files) > 0 {
 		fmt.Println("Files to transfer (to all boxes):")
 		for _, file := range opts.Workflow.Files {
-			srcPath := utils.ExpandPath(file.Source)
-			srcPath = utils.ReplaceWorkflowVars(srcPath, opts.Workflow.Vars)
-			dstPath := utils.ReplaceWorkflowVars(file.Destination, opts.Workflow.Vars)
+			srcPath, err := utils.ReplaceWorkflowVars(utils.ExpandPath(file.Source), opts.Workflow.Vars)
+			if err != nil {
+				return nil, err
+			}
+			dstPath, err := utils.ReplaceWorkflowVars(file.Destination, opts.Workflow.Vars)
+			if err != nil {
+				return nil, err
+			}
 			fmt.Printf("  %s -> %s\n", srcPath, dstPath)
 		}
 		fmt.Println()
@@ -271,6 +357,9 @@ func (c Controller) dryRunWorkflow(opts models.WorkflowOptions, fleet []provider
 		if step.Id != "" {
 			stepHeader += fmt.Sprintf(" [id: %s]", step.Id)
 		}
+		if step.From != "" {
+			stepHeader += fmt.Sprintf(" (from %s)", step.From)
+		}
 		fmt.Printf("  %s\n", stepHeader)
 
 		stepScaleMode := step.ScaleMode
@@ -286,11 +375,17 @@ func (c Controller) dryRunWorkflow(opts models.WorkflowOptions, fleet []provider
 			fmt.Printf("     split-var: %s\n", step.SplitVar)
 		}
 
-		cmdExpanded := utils.ReplaceWorkflowVars(step.Command, opts.Workflow.Vars)
+		cmdExpanded, err := utils.ReplaceWorkflowVars(step.Command, opts.Workflow.Vars)
+		if err != nil {
+			return nil, err
+		}
 		fmt.Printf("     $ %s\n", cmdExpanded)
 		if step.Timeout != "" {
 			fmt.Printf("     timeout: %s\n", step.Timeout)
 		}
+		if step.Collect {
+			fmt.Printf("     collect: %s\n", collectOutputPath(opts.Output, collectKey(step, i)))
+		}
 	}
 	fmt.Println()
 
@@ -302,10 +397,34 @@ func (c Controller) dryRunWorkflow(opts models.WorkflowOptions, fleet []provider
 		fmt.Printf("  deduplicate: true\n")
 	}
 
+	if len(opts.Workflow.Matrix) > 0 {
+		combinations := utils.ExpandMatrix(opts.Workflow.Matrix)
+		fmt.Printf("\nMatrix (%s, %d runs):\n", matrixMode(opts.Workflow), len(combinations))
+		partitions := partitionFleet(fleet, len(combinations))
+		for i, combination := range combinations {
+			suffix := utils.MatrixSuffix(combination)
+			fmt.Printf("  %d. %s\n", i+1, suffix)
+			fmt.Printf("     output: %s\n", collectOutputPath(opts.Output, suffix))
+			if matrixMode(opts.Workflow) == models.MatrixModeParallel && i < len(partitions) {
+				fmt.Printf("     boxes: %d\n", len(partitions[i]))
+			}
+		}
+	}
+
 	return []models.WorkflowResult{}, nil
 }
 
-func (c Controller) runSetupCommands(fleet []provider.Box, commands []string, port int, username, privateKeyPath string) error {
+func (c Controller) runSetupCommands(fleet []provider.Box, commands []string, vars map[string]string, port int, username, privateKeyPath string) error {
+	expanded := make([]string, len(commands))
+	for i, cmd := range commands {
+		cmd, err := utils.ReplaceWorkflowVars(cmd, vars)
+		if err != nil {
+			return err
+		}
+		expanded[i] = cmd
+	}
+	commands = expanded
+
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(fleet))
 
@@ -314,7 +433,7 @@ func (c Controller) runSetupCommands(fleet []provider.Box, commands []string, po
 		go func(b provider.Box) {
 			defer wg.Done()
 			for _, cmd := range commands {
-				output, err := sshutils.RunCommandWithOutput(cmd, b.IP, port, username, privateKeyPath)
+				output, err := c.dialer.RunCommandWithOutput(cmd, b.IP, port, username, privateKeyPath)
 				if err != nil {
 					outputStr := strings.TrimSpace(string(output))
 					if outputStr != "" {
@@ -337,81 +456,64 @@ func (c Controller) runSetupCommands(fleet []provider.Box, commands []string, po
 	return nil
 }
 
-func (c Controller) splitInputIntoChunks(inputFile, outputDir, fleetName string, numChunks int) ([]string, error) {
-	file, err := os.Open(inputFile)
-	if err != nil {
-		return nil, err
+// splitInputIntoChunks splits inputFile into one chunk per box using the
+// given shard strategy. Boxes left without lines get an empty chunk path.
+func (c Controller) splitInputIntoChunks(inputFile, outputDir, chunkPrefix string, fleet []provider.Box, strategy string, compress bool) ([]string, error) {
+	chunkFiles := make([]string, len(fleet))
+	for i := range fleet {
+		chunkFiles[i] = filepath.Join(outputDir, fmt.Sprintf("chunk-%s-%d", chunkPrefix, i+1))
+		if compress {
+			chunkFiles[i] += ".gz"
+		}
 	}
-	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
+	counts, err := utils.ShardFile(inputFile, chunkFiles, strategy, shardWeights(fleet), compress)
+	if err != nil {
 		return nil, err
 	}
 
-	if len(lines) == 0 {
-		return nil, fmt.Errorf("input file is empty")
-	}
-
-	linesPerChunk := len(lines) / numChunks
-	remainder := len(lines) % numChunks
-
-	var chunkFiles []string
-	lineIndex := 0
-
-	for i := 0; i < numChunks; i++ {
-		chunkSize := linesPerChunk
-		if i < remainder {
-			chunkSize++
-		}
-
-		if lineIndex >= len(lines) {
-			break
-		}
-
-		endIndex := lineIndex + chunkSize
-		if endIndex > len(lines) {
-			endIndex = len(lines)
-		}
-
-		chunkFileName := filepath.Join(outputDir, fmt.Sprintf("chunk-%s-%d", fleetName, i+1))
-		chunkContent := strings.Join(lines[lineIndex:endIndex], "\n") + "\n"
-
-		if err := os.WriteFile(chunkFileName, []byte(chunkContent), 0644); err != nil {
-			return nil, err
+	total := 0
+	for i, count := range counts {
+		total += count
+		if count == 0 {
+			os.Remove(chunkFiles[i])
+			chunkFiles[i] = ""
 		}
-
-		chunkFiles = append(chunkFiles, chunkFileName)
-		lineIndex = endIndex
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("input file is empty")
 	}
 
 	return chunkFiles, nil
 }
 
-func (c Controller) runWorkflowOnBox(item boxWithChunk, opts models.WorkflowOptions, port int, username, privateKeyPath, tempFolder, timeStamp string, progress *ui.WorkflowProgress) models.WorkflowResult {
+func (c Controller) runWorkflowOnBox(item boxWithChunk, opts models.WorkflowOptions, port int, username, privateKeyPath, tempFolder, timeStamp string, progress *ui.WorkflowProgress, stream *findingStream) models.WorkflowResult {
 	result := models.WorkflowResult{
 		BoxName:     item.box.Label,
 		StepResults: make([]models.WorkflowStepResult, 0),
 	}
 
-	conn, err := sshutils.Connect(item.box.IP+":"+strconv.Itoa(port), username, privateKeyPath)
+	conn, err := c.dialer.Connect(item.box.IP+":"+strconv.Itoa(port), username, privateKeyPath)
 	if err != nil {
 		result.Error = fmt.Errorf("SSH connection failed: %w", err)
 		return result
 	}
 	defer conn.Close()
 
+	transfer, err := c.newTransferer(conn, *item.box)
+	if err != nil {
+		result.Error = fmt.Errorf("file transfer setup failed: %w", err)
+		return result
+	}
+	defer transfer.Close()
+
 	var currentInput string
 	remoteSplitVarFiles := make(map[string]string)
 
 	if item.scaleMode == "vertical" && item.splitVar != "" {
 		if chunkPath, ok := item.splitVarChunks[item.splitVar]; ok && chunkPath != "" {
 			remotePath := fmt.Sprintf("/tmp/fleex-%s-splitvar-%s-%s", timeStamp, item.splitVar, item.box.Label)
-			err = scp.NewSCP(conn.Client).SendFile(chunkPath, remotePath)
+			err = sendChunk(conn, transfer, chunkPath, remotePath)
 			if err != nil {
 				result.Error = fmt.Errorf("failed to send split-var chunk: %w", err)
 				return result
@@ -422,7 +524,7 @@ func (c Controller) runWorkflowOnBox(item boxWithChunk, opts models.WorkflowOpti
 	} else {
 		if item.chunkFile != "" {
 			remoteChunkInput := fmt.Sprintf("/tmp/fleex-%s-chunk-%s", timeStamp, item.box.Label)
-			err = scp.NewSCP(conn.Client).SendFile(item.chunkFile, remoteChunkInput)
+			err = sendChunk(conn, transfer, item.chunkFile, remoteChunkInput)
 			if err != nil {
 				result.Error = fmt.Errorf("failed to send input chunk: %w", err)
 				return result
@@ -434,7 +536,7 @@ func (c Controller) runWorkflowOnBox(item boxWithChunk, opts models.WorkflowOpti
 	for varName, chunkPath := range item.splitVarChunks {
 		if _, exists := remoteSplitVarFiles[varName]; !exists && chunkPath != "" {
 			remotePath := fmt.Sprintf("/tmp/fleex-%s-splitvar-%s-%s", timeStamp, varName, item.box.Label)
-			err = scp.NewSCP(conn.Client).SendFile(chunkPath, remotePath)
+			err = sendChunk(conn, transfer, chunkPath, remotePath)
 			if err != nil {
 				result.Error = fmt.Errorf("failed to send split-var %s chunk: %w", varName, err)
 				return result
@@ -446,6 +548,13 @@ func (c Controller) runWorkflowOnBox(item boxWithChunk, opts models.WorkflowOpti
 	var currentOutput string
 	stepOutputs := make(map[string]string)
 
+	stopStream := func() {}
+	if stream != nil && len(opts.Workflow.Steps) > 0 {
+		finalOutput := fmt.Sprintf("/tmp/fleex-%s-step-%d-%s", timeStamp, len(opts.Workflow.Steps)-1, item.box.Label)
+		stopStream = stream.tail(conn, item.box.Label, finalOutput)
+	}
+	defer stopStream()
+
 	for i, step := range opts.Workflow.Steps {
 		if progress != nil {
 			progress.UpdateStep(item.box.Label, step.Name, i+1)
@@ -497,13 +606,21 @@ func (c Controller) runWorkflowOnBox(item boxWithChunk, opts models.WorkflowOpti
 			command = strings.ReplaceAll(command, placeholder, stepOutput)
 		}
 
-		command = utils.ReplaceWorkflowVars(command, vars)
-
 		stepResult := models.WorkflowStepResult{
 			StepName: step.Name,
 		}
 
-		output, err := sshutils.RunCommandWithOutput(command, item.box.IP, port, username, privateKeyPath)
+		command, err = utils.ReplaceWorkflowVars(command, vars)
+		if err != nil {
+			stepResult.Output = err.Error()
+			result.Error = fmt.Errorf("step %s failed: %w", step.Name, err)
+			result.StepResults = append(result.StepResults, stepResult)
+			return result
+		}
+
+		stepStart := time.Now()
+		output, err := c.dialer.RunCommandWithOutput(command, item.box.IP, port, username, privateKeyPath)
+		stepResult.Duration = time.Since(stepStart)
 		if err != nil {
 			stepResult.Success = false
 			outputStr := strings.TrimSpace(string(output))
@@ -519,6 +636,20 @@ func (c Controller) runWorkflowOnBox(item boxWithChunk, opts models.WorkflowOpti
 		}
 
 		stepResult.Success = true
+
+		if step.Collect {
+			collectDir := filepath.Join(tempFolder, "collect", collectKey(step, i))
+			utils.MakeFolder(filepath.Join(tempFolder, "collect"))
+			utils.MakeFolder(collectDir)
+			localCollectFile := filepath.Join(collectDir, fmt.Sprintf("output-%s", item.box.Label))
+			err = transfer.Download(currentOutput, localCollectFile)
+			if err != nil {
+				utils.Log.Warnf("[%s] failed to collect output of step %s: %v", item.box.Label, step.Name, err)
+			} else {
+				stepResult.Collected = localCollectFile
+			}
+		}
+
 		result.StepResults = append(result.StepResults, stepResult)
 
 		if step.Id != "" {
@@ -529,14 +660,20 @@ func (c Controller) runWorkflowOnBox(item boxWithChunk, opts models.WorkflowOpti
 	}
 
 	localOutputFile := filepath.Join(tempFolder, "output", fmt.Sprintf("output-%s", item.box.Label))
-	err = scp.NewSCP(conn.Client).ReceiveFile(currentOutput, localOutputFile)
+	err = transfer.Download(currentOutput, localOutputFile)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to receive output: %w", err)
 		return result
 	}
 
+	if stream != nil {
+		stopStream()
+		stopStream = func() {}
+		stream.addFile(item.box.Label, localOutputFile)
+	}
+
 	cleanupCmd := fmt.Sprintf("rm -f /tmp/fleex-%s-*", timeStamp)
-	sshutils.RunCommandSilent(cleanupCmd, item.box.IP, port, username, privateKeyPath)
+	c.dialer.RunCommandSilent(cleanupCmd, item.box.IP, port, username, privateKeyPath)
 
 	result.Success = true
 	return result
@@ -593,6 +730,33 @@ func (c Controller) aggregateResults(outputDir, finalOutput string, outputConfig
 	return os.WriteFile(finalOutput, []byte(output), 0644)
 }
 
+// collectKey returns the name used for the collected output of a step: its id
+// if set, otherwise a sanitized version of its name.
+func collectKey(step models.WorkflowStep, index int) string {
+	if step.Id != "" {
+		return step.Id
+	}
+
+	key := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(step.Name))
+	key = strings.Trim(key, "-")
+	if key == "" {
+		key = fmt.Sprintf("step-%d", index+1)
+	}
+	return key
+}
+
+// collectOutputPath derives the local file for a collected step from the final
+// output path, e.g. results.txt -> results-httpx.txt
+func collectOutputPath(output, key string) string {
+	ext := filepath.Ext(output)
+	return strings.TrimSuffix(output, ext) + "-" + key + ext
+}
+
 func uniqueStrings(input []string) []string {
 	seen := make(map[string]bool)
 	var result []string
@@ -614,19 +778,33 @@ func (c Controller) transferFilesToFleet(fleet []provider.Box, files []models.Fi
 		go func(b provider.Box) {
 			defer wg.Done()
 
-			conn, err := sshutils.Connect(b.IP+":"+strconv.Itoa(port), username, privateKeyPath)
+			conn, err := c.dialer.Connect(b.IP+":"+strconv.Itoa(port), username, privateKeyPath)
 			if err != nil {
 				errChan <- fmt.Errorf("[%s] connection failed: %w", b.Label, err)
 				return
 			}
 			defer conn.Close()
 
+			transfer, err := c.newTransferer(conn, b)
+			if err != nil {
+				errChan <- fmt.Errorf("[%s] %w", b.Label, err)
+				return
+			}
+			defer transfer.Close()
+
 			for _, file := range files {
-				srcPath := utils.ExpandPath(file.Source)
-				srcPath = utils.ReplaceWorkflowVars(srcPath, vars)
-				dstPath := utils.ReplaceWorkflowVars(file.Destination, vars)
+				srcPath, err := utils.ReplaceWorkflowVars(utils.ExpandPath(file.Source), vars)
+				if err != nil {
+					errChan <- fmt.Errorf("[%s] %w", b.Label, err)
+					return
+				}
+				dstPath, err := utils.ReplaceWorkflowVars(file.Destination, vars)
+				if err != nil {
+					errChan <- fmt.Errorf("[%s] %w", b.Label, err)
+					return
+				}
 
-				err := scp.NewSCP(conn.Client).SendFile(srcPath, dstPath)
+				err = transfer.Upload(srcPath, dstPath)
 				if err != nil {
 					errChan <- fmt.Errorf("[%s] failed to transfer %s: %w", b.Label, file.Source, err)
 					return
@@ -0,0 +1,15 @@
+package models
+
+// AutoscaleOptions controls the fleet size during a queue-based scan: the
+// input is split in chunks of ChunkSize lines that boxes take one at a time,
+// and boxes are added while the backlog is high, between Min and Max
+type AutoscaleOptions struct {
+	Min       int
+	Max       int
+	ChunkSize int
+	// Recipe is built on the boxes added during the scan, if set
+	Recipe *BuildRecipe
+	// Budget is the maximum estimated cost of the fleet in USD, 0 for none
+	Budget     float64
+	HourlyCost float64
+}
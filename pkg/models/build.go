@@ -12,6 +12,7 @@ type BuildRecipe struct {
 	Steps       []BuildStep       `yaml:"steps"`
 	Verify      []VerifyStep      `yaml:"verify,omitempty"`
 	Vars        map[string]string `yaml:"vars,omitempty"`
+	Params      []Param           `yaml:"params,omitempty"`
 }
 
 type OSConfig struct {
@@ -47,6 +48,11 @@ type BuildOptions struct {
 	ContinueErr bool
 	DryRun      bool
 	Verbose     bool
+	// Boxes restricts the build to these box labels, all boxes if empty
+	Boxes []string
+	// ReplaceFailed replaces the boxes failing the build with new boxes of
+	// the same label and builds them again
+	ReplaceFailed bool
 }
 
 type BuildResult struct {
@@ -55,6 +61,8 @@ type BuildResult struct {
 	Steps    []StepResult
 	Duration time.Duration
 	Error    error
+	// Replaced is set when the box was replaced after a failed build
+	Replaced bool
 }
 
 type StepResult struct {
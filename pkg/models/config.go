@@ -5,10 +5,11 @@ import (
 )
 
 type Config struct {
-	Providers map[string]Provider `json:"providers"`
-	CustomVMs []CustomVM          `json:"custom_vms"`
-	SSHKeys   SSHKeys             `json:"ssh_keys"`
-	Settings  Settings            `json:"settings"`
+	Providers     map[string]Provider `json:"providers"`
+	CustomVMs     []CustomVM          `json:"custom_vms"`
+	SSHKeys       SSHKeys             `json:"ssh_keys"`
+	Settings      Settings            `json:"settings"`
+	Notifications []Notification      `json:"notifications,omitempty"`
 }
 
 type Provider struct {
@@ -20,6 +21,39 @@ type Provider struct {
 	Username string   `json:"username,omitempty"`
 	Password string   `json:"password,omitempty"`
 	Tags     []string `json:"tags,omitempty"`
+	// Transfer selects the file transfer backend: scp (default) or sftp
+	Transfer string `json:"transfer,omitempty"`
+	// Regions spreads boxes across several regions instead of Region
+	Regions []string `json:"regions,omitempty"`
+	// RegionStrategy is round-robin (default) or fill
+	RegionStrategy string `json:"region_strategy,omitempty"`
+	// MaxPerRegion caps the boxes of a fleet in each region, 0 for no limit
+	MaxPerRegion int `json:"max_per_region,omitempty"`
+	// UserData is the user_data template run by cloud-init at boot, a path
+	// or a file name in the userdata folder
+	UserData string `json:"user_data,omitempty"`
+	// UserDataVars are extra variables available to the user_data template
+	UserDataVars map[string]string `json:"user_data_vars,omitempty"`
+	// ProxyJump lists the jump hosts boxes are reached through, in the
+	// OpenSSH form [user@]host[:port], comma separated and chained in order
+	ProxyJump string `json:"proxy_jump,omitempty"`
+	// ProxyJumpKey is the private key for the jump hosts, ssh_keys by default
+	ProxyJumpKey string `json:"proxy_jump_key,omitempty"`
+}
+
+const (
+	// RegionRoundRobin spreads boxes evenly across the regions
+	RegionRoundRobin = "round-robin"
+	// RegionFill fills a region up to MaxPerRegion before using the next one
+	RegionFill = "fill"
+)
+
+// RegionList returns the regions boxes can be spawned in, in order of preference
+func (p Provider) RegionList() []string {
+	if len(p.Regions) > 0 {
+		return p.Regions
+	}
+	return []string{p.Region}
 }
 
 type CustomVM struct {
@@ -31,6 +65,11 @@ type CustomVM struct {
 	Password   string   `json:"password"`
 	KeyPath    string   `json:"key_path"`
 	Tags       []string `json:"tags"`
+	Transfer   string   `json:"transfer,omitempty"`
+	// ProxyJump overrides the jump hosts of the provider, none to connect
+	// directly
+	ProxyJump    string `json:"proxy_jump,omitempty"`
+	ProxyJumpKey string `json:"proxy_jump_key,omitempty"`
 }
 
 type SSHKeys struct {
@@ -39,7 +78,51 @@ type SSHKeys struct {
 }
 
 type Settings struct {
-	Provider string `json:"provider"`
+	Provider string           `json:"provider"`
+	Transfer TransferSettings `json:"transfer,omitempty"`
+	// LegacyFleetMatch also selects boxes whose label starts with the fleet
+	// name, for fleets spawned before boxes were tagged
+	LegacyFleetMatch bool `json:"legacy_fleet_match,omitempty"`
+	// ReadyTimeout is how long a new box may take to accept SSH and finish
+	// cloud-init before it is replaced (e.g. 10m)
+	ReadyTimeout string `json:"ready_timeout,omitempty"`
+	// ReplaceRetries is how many times a box that fails to boot or build is
+	// replaced by a new box with the same label (default 2)
+	ReplaceRetries int `json:"replace_retries,omitempty"`
+}
+
+const (
+	TransferSCP  = "scp"
+	TransferSFTP = "sftp"
+)
+
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// TransferSettings controls how files are moved to and from boxes
+type TransferSettings struct {
+	// Compression is empty (none), gzip or zstd
+	Compression string `json:"compression,omitempty"`
+	// Verify checks the SHA-256 of the file on the receiving end
+	Verify bool `json:"verify,omitempty"`
+	// SkipExisting doesn't transfer files already present with the same hash
+	SkipExisting bool `json:"skip_existing,omitempty"`
+}
+
+const (
+	NotificationWebhook = "webhook"
+	NotificationSlack   = "slack"
+	NotificationDiscord = "discord"
+)
+
+// Notification is a webhook receiving lifecycle events. Type selects the
+// payload format, Events filters the events sent (all if empty).
+type Notification struct {
+	Type   string   `json:"type"`
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
 }
 
 type VMInfo struct {
@@ -49,6 +132,7 @@ type VMInfo struct {
 	Username string
 	Password string
 	KeyPath  string
+	Transfer string
 }
 
 func GetVMInfo(provider, name string, config *Config) *VMInfo {
@@ -59,6 +143,7 @@ func GetVMInfo(provider, name string, config *Config) *VMInfo {
 			Username: providerConfig.Username,
 			Password: providerConfig.Password,
 			KeyPath:  config.SSHKeys.PrivateFile,
+			Transfer: providerConfig.Transfer,
 		}
 	}
 
@@ -71,6 +156,7 @@ func GetVMInfo(provider, name string, config *Config) *VMInfo {
 				Username: customVM.Username,
 				Password: customVM.Password,
 				KeyPath:  customVM.KeyPath,
+				Transfer: customVM.Transfer,
 			}
 		}
 	}
@@ -78,6 +164,27 @@ func GetVMInfo(provider, name string, config *Config) *VMInfo {
 	return nil
 }
 
+// CustomVMByID returns the custom VM with the given instance ID
+func (c *Config) CustomVMByID(id string) (CustomVM, bool) {
+	for _, customVM := range c.CustomVMs {
+		if customVM.InstanceID == id {
+			return customVM, true
+		}
+	}
+	return CustomVM{}, false
+}
+
+// TransferBackend returns the file transfer backend configured for a box of
+// the given provider
+func (c *Config) TransferBackend(provider, boxID string) string {
+	for _, customVM := range c.CustomVMs {
+		if customVM.InstanceID == boxID {
+			return customVM.Transfer
+		}
+	}
+	return c.Providers[provider].Transfer
+}
+
 // matchesFleetName determines if a label matches the given fleet name.
 // If name ends with -{number}, only exact matches are returned.
 // Otherwise, prefix matching is used.
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// FleetManifest is the desired state of a fleet, reconciled by fleex apply
+type FleetManifest struct {
+	Name     string            `yaml:"name"`
+	Provider string            `yaml:"provider"`
+	Region   string            `yaml:"region,omitempty"`
+	Regions  []string          `yaml:"regions,omitempty"`
+	Size     string            `yaml:"size,omitempty"`
+	Image    string            `yaml:"image,omitempty"`
+	Count    int               `yaml:"count"`
+	Build    string            `yaml:"build,omitempty"`
+	Params   map[string]string `yaml:"params,omitempty"`
+	TTL      string            `yaml:"ttl,omitempty"`
+	Tags     []string          `yaml:"tags,omitempty"`
+}
+
+// RegionList returns the regions the boxes are spread across, empty if the
+// provider default is used
+func (m FleetManifest) RegionList() []string {
+	if len(m.Regions) > 0 {
+		return m.Regions
+	}
+	if m.Region != "" {
+		return []string{m.Region}
+	}
+	return nil
+}
+
+const (
+	PlanSpawn   = "spawn"
+	PlanDelete  = "delete"
+	PlanReplace = "replace"
+	PlanRebuild = "rebuild"
+)
+
+// PlanAction is a single change needed to bring a fleet to its manifest.
+// Spawn actions carry a Count, the others target one box.
+type PlanAction struct {
+	Type   string
+	BoxID  string
+	Box    string
+	Region string
+	Count  int
+	Reason string
+}
+
+// FleetPlan is the list of changes computed by fleex plan
+type FleetPlan struct {
+	Manifest *FleetManifest
+	Current  int
+	Actions  []PlanAction
+	Expired  bool
+}
+
+// Empty reports whether the fleet already matches its manifest
+func (p *FleetPlan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// ManifestState records when a manifest was first applied, to enforce its TTL
+type ManifestState struct {
+	Name     string    `json:"name"`
+	Provider string    `json:"provider"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Applied  time.Time `json:"applied"`
+}
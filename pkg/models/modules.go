@@ -4,6 +4,12 @@ type Module struct {
 	Name        string            `yaml:"name"`
 	Description string            `yaml:"description"`
 	Author      string            `yaml:"author"`
+	Include     []string          `yaml:"include,omitempty"`
 	Vars        map[string]string `yaml:"vars"`
 	Commands    []string          `yaml:"commands"`
+	Shard       string            `yaml:"shard,omitempty"`
+	// CompressChunks gzips input chunks before upload
+	CompressChunks bool `yaml:"compress-chunks,omitempty"`
+	// Build is the recipe run on boxes added by autoscaling
+	Build string `yaml:"build,omitempty"`
 }
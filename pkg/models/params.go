@@ -0,0 +1,17 @@
+package models
+
+// Param declares an input accepted by a workflow or build recipe
+type Param struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type,omitempty"`
+	Required    bool   `yaml:"required,omitempty"`
+	Default     string `yaml:"default,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+const (
+	ParamTypeString = "string"
+	ParamTypeFile   = "file"
+	ParamTypeInt    = "int"
+	ParamTypeList   = "list"
+)
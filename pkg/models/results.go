@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+const (
+	RunKindScan     = "scan"
+	RunKindWorkflow = "workflow"
+)
+
+// RunRecord is a scan or workflow run kept in the local result store
+type RunRecord struct {
+	ID       string            `json:"id"`
+	Kind     string            `json:"kind"`
+	Workflow string            `json:"workflow"`
+	Scope    string            `json:"scope"`
+	Command  string            `json:"command,omitempty"`
+	Input    string            `json:"input,omitempty"`
+	Output   string            `json:"output,omitempty"`
+	Vars     map[string]string `json:"vars,omitempty"`
+	Provider string            `json:"provider"`
+	Fleet    RunFleet          `json:"fleet"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+	Results  []RunBoxResult    `json:"results,omitempty"`
+	Lines    int               `json:"lines"`
+}
+
+type RunFleet struct {
+	Name  string   `json:"name"`
+	Boxes []RunBox `json:"boxes"`
+}
+
+type RunBox struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	IP    string `json:"ip"`
+}
+
+type RunBoxResult struct {
+	Box     string          `json:"box"`
+	Success bool            `json:"success"`
+	Error   string          `json:"error,omitempty"`
+	Steps   []RunStepResult `json:"steps,omitempty"`
+}
+
+type RunStepResult struct {
+	Name     string        `json:"name"`
+	Success  bool          `json:"success"`
+	Duration time.Duration `json:"duration"`
+	Output   string        `json:"output,omitempty"`
+}
+
+// DiffOptions enables the comparison of a run against the previous run of
+// the same workflow and scope
+type DiffOptions struct {
+	Enabled bool
+	// Key is the JSON field used to compare lines. Lines are compared
+	// as a whole when empty.
+	Key   string
+	Scope string
+}
+
+// DiffResult holds the lines of a run compared to a previous one
+type DiffResult struct {
+	New       []string
+	Removed   []string
+	Unchanged []string
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// RunOptions describes a command sent to a fleet with fleex run
+type RunOptions struct {
+	FleetName string
+	Command   string
+	Parallel  int
+	// OutputDir receives one <box>.txt file with the output of each box
+	OutputDir string
+	// Port and Username override the SSH settings of every box when set
+	Port     int
+	Username string
+	// KeyPath is the key of boxes without a fleet or custom VM key
+	KeyPath string
+}
+
+type RunResult struct {
+	BoxName    string
+	ExitCode   int
+	Duration   time.Duration
+	OutputFile string
+	Error      error
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ScheduledJob describes a workflow that the daemon runs on a cron schedule
+// on a dedicated, short-lived fleet
+type ScheduledJob struct {
+	Name      string            `yaml:"name"`
+	Cron      string            `yaml:"cron"`
+	Workflow  string            `yaml:"workflow"`
+	Input     string            `yaml:"input"`
+	FleetName string            `yaml:"fleet-name,omitempty"`
+	FleetSize int               `yaml:"fleet-size"`
+	Provider  string            `yaml:"provider,omitempty"`
+	Build     string            `yaml:"build,omitempty"`
+	Retention int               `yaml:"retention,omitempty"`
+	Params    map[string]string `yaml:"params,omitempty"`
+	Diff      bool              `yaml:"diff,omitempty"`
+	DiffKey   string            `yaml:"diff-key,omitempty"`
+}
+
+// ScheduleRun is an entry of the run history of a scheduled job
+type ScheduleRun struct {
+	Job        string    `json:"job"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	Success    bool      `json:"success"`
+	Skipped    bool      `json:"skipped,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Boxes      int       `json:"boxes"`
+	Successful int       `json:"successful"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// SCPOptions describes a fleex scp transfer
+type SCPOptions struct {
+	// Sources are local paths or globs, or remote ones with FromFleet
+	Sources     []string
+	Destination string
+	// FromFleet pulls Sources from every box into Destination/<box>
+	FromFleet bool
+	Port      int
+	Username  string
+	KeyPath   string
+}
@@ -0,0 +1,17 @@
+package models
+
+// Strategies used to split an input file across the boxes of a fleet
+const (
+	// ShardContiguous gives each box a contiguous block of lines (default)
+	ShardContiguous = "contiguous"
+	// ShardRoundRobin interleaves lines across boxes
+	ShardRoundRobin = "round-robin"
+	// ShardApex keeps every host of the same registered domain on one box
+	ShardApex = "apex"
+	// ShardSubnet keeps every IP of the same /24 on one box
+	ShardSubnet = "subnet24"
+	// ShardWeighted gives each box a contiguous block proportional to its CPUs
+	ShardWeighted = "weighted"
+)
+
+var ShardStrategies = []string{ShardContiguous, ShardRoundRobin, ShardApex, ShardSubnet, ShardWeighted}
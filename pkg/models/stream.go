@@ -0,0 +1,16 @@
+package models
+
+// StreamOptions forwards the lines written by the boxes while a scan is
+// running, before the results are aggregated
+type StreamOptions struct {
+	Stdout      bool
+	File        string
+	Webhook     string
+	WebhookType string
+	// Match only streams the lines matching this regular expression
+	Match string
+}
+
+func (s StreamOptions) Enabled() bool {
+	return s.Stdout || s.File != "" || s.Webhook != ""
+}
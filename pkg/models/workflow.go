@@ -1,16 +1,28 @@
 package models
 
+import "time"
+
 type Workflow struct {
-	Name        string            `yaml:"name"`
-	Description string            `yaml:"description"`
-	Author      string            `yaml:"author"`
-	Vars        map[string]string `yaml:"vars"`
-	Files       []FileTransfer    `yaml:"files,omitempty"`
-	Setup       []string          `yaml:"setup,omitempty"`
-	Steps       []WorkflowStep    `yaml:"steps"`
-	Output      WorkflowOutput    `yaml:"output,omitempty"`
-	ScaleMode   string            `yaml:"scale-mode,omitempty"`
-	SplitVar    string            `yaml:"split-var,omitempty"`
+	Name        string              `yaml:"name"`
+	Description string              `yaml:"description"`
+	Author      string              `yaml:"author"`
+	Include     []string            `yaml:"include,omitempty"`
+	Vars        map[string]string   `yaml:"vars"`
+	Params      []Param             `yaml:"params,omitempty"`
+	Files       []FileTransfer      `yaml:"files,omitempty"`
+	Setup       []string            `yaml:"setup,omitempty"`
+	Steps       []WorkflowStep      `yaml:"steps"`
+	Output      WorkflowOutput      `yaml:"output,omitempty"`
+	ScaleMode   string              `yaml:"scale-mode,omitempty"`
+	SplitVar    string              `yaml:"split-var,omitempty"`
+	Matrix      map[string][]string `yaml:"matrix,omitempty"`
+	MatrixMode  string              `yaml:"matrix-mode,omitempty"`
+	Shard       string              `yaml:"shard,omitempty"`
+	// CompressChunks gzips input chunks before upload
+	CompressChunks bool `yaml:"compress-chunks,omitempty"`
+
+	// Included lists the workflows that were expanded into this one
+	Included []string `yaml:"-"`
 }
 
 type WorkflowStep struct {
@@ -20,6 +32,13 @@ type WorkflowStep struct {
 	Timeout   string `yaml:"timeout,omitempty"`
 	ScaleMode string `yaml:"scale-mode,omitempty"`
 	SplitVar  string `yaml:"split-var,omitempty"`
+	Collect   bool   `yaml:"collect,omitempty"`
+
+	Uses string            `yaml:"uses,omitempty"`
+	With map[string]string `yaml:"with,omitempty"`
+
+	// From is the workflow the step was included from, if any
+	From string `yaml:"-"`
 }
 
 type WorkflowOutput struct {
@@ -36,6 +55,8 @@ type WorkflowOptions struct {
 	Delete       bool
 	DryRun       bool
 	Verbose      bool
+	Diff         DiffOptions
+	Stream       StreamOptions
 }
 
 type WorkflowResult struct {
@@ -46,7 +67,23 @@ type WorkflowResult struct {
 }
 
 type WorkflowStepResult struct {
-	StepName string
-	Success  bool
-	Output   string
+	StepName  string
+	Success   bool
+	Output    string
+	Collected string
+	Duration  time.Duration
+}
+
+const (
+	MatrixModeSequential = "sequential"
+	MatrixModeParallel   = "parallel"
+)
+
+// MatrixRun is the result of a single matrix combination
+type MatrixRun struct {
+	Vars    map[string]string
+	Suffix  string
+	Output  string
+	Results []WorkflowResult
+	Error   error
 }
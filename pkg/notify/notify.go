@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+)
+
+const (
+	EventSpawnComplete = "spawn.complete"
+	EventBuildFailed   = "build.failed"
+	EventBoxFailed     = "box.failed"
+	EventScanComplete  = "scan.complete"
+	EventFinding       = "scan.finding"
+	EventTest          = "test"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Event is a lifecycle event sent to the configured notifications
+type Event struct {
+	Type    string            `json:"event"`
+	Title   string            `json:"title"`
+	Message string            `json:"message,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Time    time.Time         `json:"time"`
+}
+
+// Send delivers an event to every notification subscribed to it
+func Send(notifications []models.Notification, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+
+	for _, n := range notifications {
+		if !subscribed(n, event.Type) {
+			continue
+		}
+		wg.Add(1)
+		go func(n models.Notification) {
+			defer wg.Done()
+			if err := SendTo(n, event); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// SendTo delivers an event to a single notification, ignoring its filter
+func SendTo(n models.Notification, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	payload, err := buildPayload(n.Type, event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(n.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%s notification: %w", n.Type, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s notification: unexpected status %s", n.Type, resp.Status)
+	}
+	return nil
+}
+
+func subscribed(n models.Notification, eventType string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+	for _, e := range n.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func buildPayload(notificationType string, event Event) ([]byte, error) {
+	switch notificationType {
+	case models.NotificationWebhook, "":
+		return json.Marshal(event)
+
+	case models.NotificationSlack:
+		text := "*" + event.Title + "*"
+		if event.Message != "" {
+			text += "\n" + event.Message
+		}
+		for _, k := range sortedKeys(event.Fields) {
+			text += fmt.Sprintf("\n• %s: %s", k, event.Fields[k])
+		}
+		return json.Marshal(map[string]string{"text": text})
+
+	case models.NotificationDiscord:
+		type field struct {
+			Name   string `json:"name"`
+			Value  string `json:"value"`
+			Inline bool   `json:"inline"`
+		}
+		embed := map[string]interface{}{
+			"title":       event.Title,
+			"description": truncate(event.Message, 4000),
+			"timestamp":   event.Time.Format(time.RFC3339),
+		}
+		var fields []field
+		for _, k := range sortedKeys(event.Fields) {
+			fields = append(fields, field{Name: k, Value: truncate(event.Fields[k], 1000), Inline: true})
+		}
+		if len(fields) > 0 {
+			embed["fields"] = fields
+		}
+		return json.Marshal(map[string]interface{}{"embeds": []interface{}{embed}})
+
+	default:
+		return nil, fmt.Errorf("unknown notification type: %s", notificationType)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// truncate shortens s to max characters, not splitting a UTF-8 rune
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:max-3])) + "..."
+}
@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+)
+
+// receive starts a listener recording the body of every request
+func receive(t *testing.T, status int) (*httptest.Server, chan []byte) {
+	t.Helper()
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies
+}
+
+var testEvent = Event{
+	Type:    EventSpawnComplete,
+	Title:   "Fleet pwn spawned",
+	Message: "all good",
+	Fields:  map[string]string{"boxes": "3", "provider": "linode"},
+	Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func TestSendToWebhook(t *testing.T) {
+	server, bodies := receive(t, http.StatusOK)
+
+	if err := SendTo(models.Notification{Type: models.NotificationWebhook, URL: server.URL}, testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Event
+	if err := json.Unmarshal(<-bodies, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != testEvent.Type || got.Title != testEvent.Title || got.Message != testEvent.Message {
+		t.Errorf("got %+v, want %+v", got, testEvent)
+	}
+	if got.Fields["boxes"] != "3" || got.Fields["provider"] != "linode" {
+		t.Errorf("fields = %v", got.Fields)
+	}
+	if !got.Time.Equal(testEvent.Time) {
+		t.Errorf("time = %v, want %v", got.Time, testEvent.Time)
+	}
+}
+
+func TestSendToSlack(t *testing.T) {
+	server, bodies := receive(t, http.StatusOK)
+
+	if err := SendTo(models.Notification{Type: models.NotificationSlack, URL: server.URL}, testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(<-bodies, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := "*Fleet pwn spawned*\nall good\n• boxes: 3\n• provider: linode"
+	if got["text"] != want {
+		t.Errorf("text = %q, want %q", got["text"], want)
+	}
+}
+
+func TestSendToDiscord(t *testing.T) {
+	server, bodies := receive(t, http.StatusNoContent)
+
+	if err := SendTo(models.Notification{Type: models.NotificationDiscord, URL: server.URL}, testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Embeds []struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			Timestamp   string `json:"timestamp"`
+			Fields      []struct {
+				Name   string `json:"name"`
+				Value  string `json:"value"`
+				Inline bool   `json:"inline"`
+			} `json:"fields"`
+		} `json:"embeds"`
+	}
+	if err := json.Unmarshal(<-bodies, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Embeds) != 1 {
+		t.Fatalf("got %d embeds, want 1", len(got.Embeds))
+	}
+	embed := got.Embeds[0]
+	if embed.Title != testEvent.Title || embed.Description != testEvent.Message {
+		t.Errorf("embed = %+v", embed)
+	}
+	if embed.Timestamp != "2024-05-01T12:00:00Z" {
+		t.Errorf("timestamp = %q", embed.Timestamp)
+	}
+	if len(embed.Fields) != 2 || embed.Fields[0].Name != "boxes" || embed.Fields[0].Value != "3" || !embed.Fields[0].Inline {
+		t.Errorf("fields = %+v", embed.Fields)
+	}
+}
+
+func TestSendToErrorStatus(t *testing.T) {
+	server, _ := receive(t, http.StatusInternalServerError)
+
+	err := SendTo(models.Notification{Type: models.NotificationWebhook, URL: server.URL}, testEvent)
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("err = %v, want an unexpected status error", err)
+	}
+}
+
+func TestSendFiltersEvents(t *testing.T) {
+	server, bodies := receive(t, http.StatusOK)
+
+	notifications := []models.Notification{
+		{Type: models.NotificationWebhook, URL: server.URL, Events: []string{EventBuildFailed}},
+		{Type: models.NotificationWebhook, URL: server.URL, Events: []string{EventSpawnComplete}},
+		{Type: models.NotificationWebhook, URL: server.URL},
+	}
+	if err := Send(notifications, testEvent); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Errorf("got %d requests, want 2", len(bodies))
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"this is too long", 10, "this is..."},
+		{"ééééééééééééé", 10, "ééééééé..."},
+		{"🔥🔥🔥🔥🔥🔥", 5, "🔥🔥..."},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.in, tt.max)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q is not valid UTF-8", tt.in, tt.max, got)
+		}
+	}
+}
@@ -6,6 +6,10 @@ type Box struct {
 	Group  string
 	Status string
 	IP     string
+	Size   string
+	CPUs   int
+	Tags   []string
+	Region string
 }
 
 type Image struct {
@@ -20,6 +24,7 @@ type Image struct {
 
 type Provider interface {
 	SpawnFleet(fleetName string, fleetCount int) error
+	SpawnBoxes(fleetName string, labels []string) error
 	GetBoxes() (boxes []Box, err error)
 	GetFleet(fleetName string) (fleet []Box, err error)
 	GetBox(boxName string) (Box, error)
@@ -34,4 +39,5 @@ type Provider interface {
 	CreateImage(diskID int, label string) error
 	TransferImage(imageID int, region string) error
 	GetImageRegions(imageID int) ([]string, error)
+	DeleteSSHKey(fleetName string) error
 }
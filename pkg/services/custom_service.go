@@ -12,12 +12,22 @@ import (
 
 type CustomService struct {
 	Configs *models.Config
+	Dialer  *sshutils.Dialer
 }
 
 func (c CustomService) SpawnFleet(fleetName string, fleetCount int) error {
 	return models.ErrNotAvailableCustomVps
 }
 
+func (c CustomService) SpawnBoxes(fleetName string, labels []string) error {
+	return models.ErrNotAvailableCustomVps
+}
+
+// DeleteSSHKey does nothing, custom VMs use their own keys
+func (c CustomService) DeleteSSHKey(fleetName string) error {
+	return nil
+}
+
 func (c CustomService) GetBoxes() (boxes []provider.Box, err error) {
 	customVps := c.Configs.CustomVMs
 
@@ -28,6 +38,7 @@ func (c CustomService) GetBoxes() (boxes []provider.Box, err error) {
 			Group:  "custom",
 			Status: "unknown",
 			IP:     vps.PublicIP,
+			Tags:   vps.Tags,
 		})
 	}
 	return boxes, nil
@@ -39,8 +50,10 @@ func (c CustomService) GetFleet(fleetName string) (fleet []provider.Box, err err
 		return []provider.Box{}, err
 	}
 
+	// Custom VMs are never spawned or deleted by fleex, so their ID prefix
+	// still selects them along with the fleet tag
 	for _, box := range boxes {
-		if utils.MatchesFleetName(box.ID, fleetName) {
+		if utils.BoxInFleet(box.ID, box.Tags, fleetName, false, true) {
 			fleet = append(fleet, box)
 		}
 	}
@@ -88,7 +101,7 @@ func (c CustomService) DeleteBoxByLabel(label string) error {
 func (c CustomService) RunCommand(name, command string, port int, username, password string) error {
 	for _, box := range c.Configs.CustomVMs {
 		if utils.MatchesFleetName(box.InstanceID, name) {
-			sshutils.RunCommand(command, box.PublicIP, box.SSHPort, box.Username, c.Configs.SSHKeys.PrivateFile)
+			c.Dialer.RunCommand(command, box.PublicIP, box.SSHPort, box.Username, c.Configs.SSHKeys.PrivateFile)
 			return nil
 		}
 	}
@@ -105,7 +118,7 @@ func (c CustomService) RunCommand(name, command string, port int, username, pass
 	for _, box := range c.Configs.CustomVMs {
 		go func(b models.CustomVM) {
 			defer wg.Done()
-			sshutils.RunCommand(command, b.PublicIP, b.SSHPort, b.Username, c.Configs.SSHKeys.PrivateFile)
+			c.Dialer.RunCommand(command, b.PublicIP, b.SSHPort, b.Username, c.Configs.SSHKeys.PrivateFile)
 		}(box)
 	}
 
@@ -18,27 +18,29 @@ import (
 type DigitaloceanService struct {
 	Client  *godo.Client
 	Configs *models.Config
+	Dialer  *sshutils.Dialer
 }
 
-func (d DigitaloceanService) ensureSSHKey() (string, error) {
+func (d DigitaloceanService) ensureSSHKey(fleetName string) (string, error) {
 	ctx := context.TODO()
-	publicKey := sshutils.GetLocalPublicSSHKey()
-	fingerprint := sshutils.SSHFingerprintGen(d.Configs.SSHKeys.PublicFile)
+	keys := utils.FleetSSHKeys(fleetName, d.Configs.SSHKeys)
+	publicKey := fleetPublicKey(d.Configs, fleetName)
+	fingerprint := sshutils.SSHFingerprintGen(keys.PublicFile)
 
 	opt := &godo.ListOptions{Page: 1, PerPage: 200}
-	keys, _, err := d.Client.Keys.List(ctx, opt)
+	existing, _, err := d.Client.Keys.List(ctx, opt)
 	if err != nil {
 		return "", err
 	}
 
-	for _, key := range keys {
+	for _, key := range existing {
 		if key.Fingerprint == fingerprint {
 			return fingerprint, nil
 		}
 	}
 
 	createRequest := &godo.KeyCreateRequest{
-		Name:      "fleex",
+		Name:      fleetKeyName(fleetName),
 		PublicKey: publicKey,
 	}
 	newKey, _, err := d.Client.Keys.Create(ctx, createRequest)
@@ -49,96 +51,151 @@ func (d DigitaloceanService) ensureSSHKey() (string, error) {
 	return newKey.Fingerprint, nil
 }
 
+// DeleteSSHKey removes the key registered for the fleet
+func (d DigitaloceanService) DeleteSSHKey(fleetName string) error {
+	ctx := context.TODO()
+	opt := &godo.ListOptions{Page: 1, PerPage: 200}
+	keys, _, err := d.Client.Keys.List(ctx, opt)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if key.Name == "fleex-"+fleetName {
+			if _, err := d.Client.Keys.DeleteByID(ctx, key.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (d DigitaloceanService) SpawnFleet(fleetName string, fleetCount int) error {
-	existingFleet, _ := d.GetFleet(fleetName)
+	existingBoxes, _ := d.GetBoxes()
+	return d.createBoxes(existingBoxes, fleetName, newBoxNames(existingBoxes, fleetName, fleetCount))
+}
+
+// SpawnBoxes creates boxes with the given labels in the fleet
+func (d DigitaloceanService) SpawnBoxes(fleetName string, labels []string) error {
+	existingBoxes, _ := d.GetBoxes()
+	return d.createBoxes(existingBoxes, fleetName, labels)
+}
+
+func (d DigitaloceanService) createBoxes(existingBoxes []provider.Box, fleetName string, names []string) error {
 	providerName := d.Configs.Settings.Provider
 	providerInfo := d.Configs.Providers[providerName]
 
 	ctx := context.TODO()
 	password := providerInfo.Password
 	if password == "" {
-		password = "1337rootPass"
+		password = generateRandomPassword(32)
 	}
 	image := providerInfo.Image
-	region := providerInfo.Region
 	size := providerInfo.Size
-	tags := providerInfo.Tags
+	tags := append([]string{utils.FleetTagStrict(fleetName)}, providerInfo.Tags...)
 
-	sshFingerprint, err := d.ensureSSHKey()
+	sshFingerprint, err := d.ensureSSHKey(fleetName)
 	if err != nil {
 		return fmt.Errorf("failed to ensure SSH key: %w", err)
 	}
 
-	droplets := []string{}
-
-	for i := 0; i < fleetCount; i++ {
-		droplets = append(droplets, fleetName+"-"+strconv.Itoa(i+1+len(existingFleet)))
+	template, err := digitaloceanUserData(providerInfo)
+	if err != nil {
+		return err
+	}
+	userData := make(map[string]string, len(names))
+	for _, name := range names {
+		userData[name], err = renderUserData(template, d.Configs, fleetName, name, password)
+		if err != nil {
+			return fmt.Errorf("invalid user_data template: %w", err)
+		}
 	}
-
-	user_data := `#!/bin/bash
-sudo sed -i "/^[^#]*PasswordAuthentication[[:space:]]no/c\PasswordAuthentication yes" /etc/ssh/sshd_config
-sudo service sshd restart
-echo 'op:` + password + `' | sudo chpasswd`
 
 	// DigitalOcean limits CreateMultiple to 10 droplets per request
 	const batchSize = 10
 	imageIntID, _ := strconv.Atoi(image)
 	isImageID := imageIntID > 0
 
-	for i := 0; i < len(droplets); i += batchSize {
-		end := i + batchSize
-		if end > len(droplets) {
-			end = len(droplets)
-		}
-		batch := droplets[i:end]
-		batchNum := (i / batchSize) + 1
-		totalBatches := (len(droplets) + batchSize - 1) / batchSize
-
-		var createRequest *godo.DropletMultiCreateRequest
-		if isImageID {
-			createRequest = &godo.DropletMultiCreateRequest{
-				Names:    batch,
-				Region:   region,
-				Size:     size,
-				UserData: user_data,
-				Image: godo.DropletCreateImage{
-					ID: imageIntID,
-				},
-				SSHKeys: []godo.DropletCreateSSHKey{
-					{Fingerprint: sshFingerprint},
-				},
-				Tags: tags,
+	placer := newRegionPlacer(providerInfo, fleetBoxes(existingBoxes, fleetName, d.Configs))
+
+	// Every pending droplet gets a region, then droplets are created region by
+	// region. Droplets of a region out of capacity go back to pending.
+	pending := names
+	for len(pending) > 0 {
+		byRegion := make(map[string][]string)
+		var regions []string
+		for _, name := range pending {
+			region, err := placer.next()
+			if err != nil {
+				return err
 			}
-		} else {
-			createRequest = &godo.DropletMultiCreateRequest{
-				Names:    batch,
-				Region:   region,
-				Size:     size,
-				UserData: user_data,
-				Image: godo.DropletCreateImage{
-					Slug: image,
-				},
-				SSHKeys: []godo.DropletCreateSSHKey{
-					{Fingerprint: sshFingerprint},
-				},
-				Tags: tags,
+			if _, ok := byRegion[region]; !ok {
+				regions = append(regions, region)
 			}
+			byRegion[region] = append(byRegion[region], name)
 		}
+		pending = nil
+
+		for _, region := range regions {
+			batches := userDataBatches(byRegion[region], userData, batchSize)
+			for i, batch := range batches {
+				createRequest := &godo.DropletMultiCreateRequest{
+					Names:    batch,
+					Region:   region,
+					Size:     size,
+					UserData: userData[batch[0]],
+					SSHKeys: []godo.DropletCreateSSHKey{
+						{Fingerprint: sshFingerprint},
+					},
+					Tags: tags,
+				}
+				if isImageID {
+					createRequest.Image = godo.DropletCreateImage{ID: imageIntID}
+				} else {
+					createRequest.Image = godo.DropletCreateImage{Slug: image}
+				}
 
-		_, _, err := d.Client.Droplets.CreateMultiple(ctx, createRequest)
-		if err != nil {
-			return fmt.Errorf("batch %d/%d failed: %w", batchNum, totalBatches, err)
-		}
+				utils.Log.Infof("Spawning %d droplets in %s", len(batch), region)
+				_, _, err := d.Client.Droplets.CreateMultiple(ctx, createRequest)
+				if err != nil {
+					var remaining []string
+					for _, b := range batches[i:] {
+						remaining = append(remaining, b...)
+					}
+					if !placer.release(region, len(remaining), err) {
+						return fmt.Errorf("batch of %d droplets failed in %s: %w", len(batch), region, err)
+					}
+					pending = append(pending, remaining...)
+					break
+				}
 
-		// Small delay between batches to avoid overwhelming the API
-		if end < len(droplets) {
-			time.Sleep(200 * time.Millisecond)
+				// Small delay between batches to avoid overwhelming the API
+				time.Sleep(200 * time.Millisecond)
+			}
 		}
 	}
 
 	return nil
 }
 
+// userDataBatches splits names into batches of at most size droplets sharing
+// the same user_data, as CreateMultiple takes a single user_data
+func userDataBatches(names []string, userData map[string]string, size int) [][]string {
+	var batches [][]string
+	var batch []string
+	for _, name := range names {
+		if len(batch) == size || (len(batch) > 0 && userData[name] != userData[batch[0]]) {
+			batches = append(batches, batch)
+			batch = nil
+		}
+		batch = append(batch, name)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
 func (d DigitaloceanService) GetFleet(fleetName string) (fleet []provider.Box, err error) {
 	boxes, err := d.GetBoxes()
 	if err != nil {
@@ -146,7 +203,7 @@ func (d DigitaloceanService) GetFleet(fleetName string) (fleet []provider.Box, e
 	}
 
 	for _, box := range boxes {
-		if utils.MatchesFleetName(box.Label, fleetName) {
+		if inFleet(box, fleetName, d.Configs) {
 			fleet = append(fleet, box)
 		}
 	}
@@ -184,7 +241,7 @@ func (d DigitaloceanService) GetBoxes() (boxes []provider.Box, err error) {
 		for _, droplet := range droplets {
 			ip, _ := droplet.PublicIPv4()
 			dID := strconv.Itoa(droplet.ID)
-			boxes = append(boxes, provider.Box{ID: dID, Label: droplet.Name, Group: "", Status: droplet.Status, IP: ip})
+			boxes = append(boxes, provider.Box{ID: dID, Label: droplet.Name, Group: "", Status: droplet.Status, IP: ip, Size: droplet.SizeSlug, CPUs: droplet.Vcpus, Tags: droplet.Tags, Region: dropletRegion(droplet)})
 		}
 
 		// Check if there are more pages
@@ -197,6 +254,13 @@ func (d DigitaloceanService) GetBoxes() (boxes []provider.Box, err error) {
 	return boxes, nil
 }
 
+func dropletRegion(droplet godo.Droplet) string {
+	if droplet.Region == nil {
+		return ""
+	}
+	return droplet.Region.Slug
+}
+
 func (d DigitaloceanService) GetImages() (images []provider.Image, err error) {
 	ctx := context.TODO()
 	opt := &godo.ListOptions{
@@ -285,7 +349,7 @@ func (d DigitaloceanService) DeleteFleet(name string) error {
 	// Continue deleting even if some fail (e.g., rate limits, transient errors)
 	var lastErr error
 	for _, droplet := range boxes {
-		if utils.MatchesFleetName(droplet.Label, name) {
+		if inFleet(droplet, name, d.Configs) {
 			err := d.DeleteBoxByID(droplet.ID)
 			if err != nil {
 				return err
@@ -327,7 +391,7 @@ func (l DigitaloceanService) DeleteBoxByLabel(label string) error {
 
 func (d DigitaloceanService) CountFleet(fleetName string, boxes []provider.Box) (count int) {
 	for _, box := range boxes {
-		if utils.MatchesFleetName(box.Label, fleetName) {
+		if inFleet(box, fleetName, d.Configs) {
 			count++
 		}
 	}
@@ -340,11 +404,9 @@ func (d DigitaloceanService) RunCommand(name, command string, port int, username
 		return err
 	}
 
-	privateKey := d.Configs.SSHKeys.PrivateFile
-
 	for _, box := range boxes {
 		if box.Label == name {
-			sshutils.RunCommand(command, box.IP, port, username, privateKey)
+			d.Dialer.RunCommand(command, box.IP, port, username, boxPrivateKey(box, d.Configs))
 			return nil
 		}
 	}
@@ -363,14 +425,14 @@ func (d DigitaloceanService) RunCommand(name, command string, port int, username
 				if box == nil {
 					break
 				}
-				sshutils.RunCommand(command, box.IP, port, username, privateKey)
+				d.Dialer.RunCommand(command, box.IP, port, username, boxPrivateKey(*box, d.Configs))
 			}
 			processGroup.Done()
 		}()
 	}
 
 	for i := range boxes {
-		if utils.MatchesFleetName(boxes[i].Label, name) {
+		if inFleet(boxes[i], name, d.Configs) {
 			fleet <- &boxes[i]
 		}
 	}
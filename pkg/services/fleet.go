@@ -0,0 +1,62 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// inFleet reports whether box belongs to the fleet name, selecting by fleet
+// tag unless legacy label matching is enabled
+func inFleet(box provider.Box, name string, configs *models.Config) bool {
+	return utils.BoxInFleet(box.Label, box.Tags, name, strictFleetTag(configs.Settings.Provider), configs.Settings.LegacyFleetMatch)
+}
+
+// strictFleetTag reports whether provider tags boxes with utils.FleetTagStrict,
+// DigitalOcean doesn't allow '=' in tags
+func strictFleetTag(provider string) bool {
+	return strings.ToLower(provider) == "digitalocean"
+}
+
+// newBoxNames returns the labels of count new boxes for the fleet, skipping
+// every label already used in the account
+func newBoxNames(boxes []provider.Box, fleetName string, count int) []string {
+	labels := make([]string, len(boxes))
+	for i, box := range boxes {
+		labels[i] = box.Label
+	}
+	return utils.NewBoxNames(fleetName, count, labels)
+}
+
+// fleetBoxes returns the boxes of the fleet name among boxes
+func fleetBoxes(boxes []provider.Box, name string, configs *models.Config) []provider.Box {
+	var fleet []provider.Box
+	for _, box := range boxes {
+		if inFleet(box, name, configs) {
+			fleet = append(fleet, box)
+		}
+	}
+	return fleet
+}
+
+// fleetPublicKey returns the public key boxes of the fleet are spawned with
+func fleetPublicKey(configs *models.Config, fleetName string) string {
+	keys := utils.FleetSSHKeys(fleetName, configs.SSHKeys)
+	return strings.TrimSpace(utils.FileToString(keys.PublicFile))
+}
+
+// fleetKeyName is the name of the key registered with the provider for the
+// fleet, "fleex" for the shared key
+func fleetKeyName(fleetName string) string {
+	if utils.OwnsFleetKeys(fleetName) {
+		return "fleex-" + fleetName
+	}
+	return "fleex"
+}
+
+// boxPrivateKey returns the private key of the fleet box was spawned in
+func boxPrivateKey(box provider.Box, configs *models.Config) string {
+	return utils.BoxSSHKeys(box.Tags, configs.SSHKeys).PrivateFile
+}
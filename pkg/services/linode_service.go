@@ -27,10 +27,28 @@ func generateRandomPassword(length int) string {
 type LinodeService struct {
 	Client  linodego.Client
 	Configs *models.Config
+	Dialer  *sshutils.Dialer
 }
 
 func (l LinodeService) SpawnFleet(fleetName string, fleetCount int) error {
-	existingFleet, _ := l.GetFleet(fleetName)
+	existingBoxes, _ := l.GetBoxes()
+	return l.createBoxes(existingBoxes, fleetName, newBoxNames(existingBoxes, fleetName, fleetCount))
+}
+
+// SpawnBoxes creates boxes with the given labels in the fleet
+func (l LinodeService) SpawnBoxes(fleetName string, labels []string) error {
+	existingBoxes, _ := l.GetBoxes()
+	return l.createBoxes(existingBoxes, fleetName, labels)
+}
+
+func (l LinodeService) createBoxes(existingBoxes []provider.Box, fleetName string, names []string) error {
+	providerInfo := l.Configs.Providers[l.Configs.Settings.Provider]
+	template, err := userDataTemplate(providerInfo, "")
+	if err != nil {
+		return err
+	}
+
+	placer := newRegionPlacer(providerInfo, fleetBoxes(existingBoxes, fleetName, l.Configs))
 	threads := 10
 	fleet := make(chan string)
 	processGroup := new(sync.WaitGroup)
@@ -41,8 +59,9 @@ func (l LinodeService) SpawnFleet(fleetName string, fleetCount int) error {
 		go func() {
 			defer processGroup.Done()
 			for box := range fleet {
-				utils.Log.Info("Spawning box ", box)
-				err := l.spawnBox(box)
+				err := spawnInRegions(placer, box, func(region string) error {
+					return l.spawnBox(box, fleetName, region, template)
+				})
 				if err != nil {
 					errChan <- err
 					return
@@ -51,8 +70,8 @@ func (l LinodeService) SpawnFleet(fleetName string, fleetCount int) error {
 		}()
 	}
 
-	for i := 0; i < fleetCount; i++ {
-		fleet <- fleetName + "-" + strconv.Itoa(i+1+len(existingFleet))
+	for _, name := range names {
+		fleet <- name
 	}
 
 	close(fleet)
@@ -73,7 +92,7 @@ func (l LinodeService) GetFleet(fleetName string) (fleet []provider.Box, err err
 	}
 
 	for _, box := range boxes {
-		if utils.MatchesFleetName(box.Label, fleetName) {
+		if inFleet(box, fleetName, l.Configs) {
 			fleet = append(fleet, box)
 		}
 	}
@@ -108,11 +127,22 @@ func (l LinodeService) GetBoxes() (boxes []provider.Box, err error) {
 			Group:  linode.Group,
 			Status: string(linode.Status),
 			IP:     linode.IPv4[0].String(),
+			Size:   linode.Type,
+			CPUs:   linodeCPUs(linode.Specs),
+			Tags:   linode.Tags,
+			Region: linode.Region,
 		})
 	}
 	return boxes, nil
 }
 
+func linodeCPUs(specs *linodego.InstanceSpec) int {
+	if specs == nil {
+		return 0
+	}
+	return specs.VCPUs
+}
+
 func (l LinodeService) GetImages() (images []provider.Image, err error) {
 	linodeImages, err := l.Client.ListImages(context.Background(), nil)
 
@@ -165,7 +195,7 @@ func (l LinodeService) RemoveImages(name string) error {
 	return errors.New("Image not found")
 }
 
-func (l LinodeService) spawnBox(name string) error {
+func (l LinodeService) spawnBox(name, fleetName, region, template string) error {
 	providerName := l.Configs.Settings.Provider
 	providerInfo := l.Configs.Providers[providerName]
 	swapSize := 512
@@ -176,16 +206,27 @@ func (l LinodeService) spawnBox(name string) error {
 		rootPass = generateRandomPassword(32)
 	}
 
+	userData, err := renderUserData(template, l.Configs, fleetName, name, rootPass)
+	if err != nil {
+		return fmt.Errorf("invalid user_data template: %w", err)
+	}
+	var metadata *linodego.InstanceMetadataOptions
+	if userData != "" {
+		metadata = &linodego.InstanceMetadataOptions{UserData: encodeUserData(userData)}
+	}
+
 	for {
 		instance, err := l.Client.CreateInstance(context.Background(), linodego.InstanceCreateOptions{
 			SwapSize:       &swapSize,
 			Image:          providerInfo.Image,
 			RootPass:       rootPass,
 			Type:           providerInfo.Size,
-			Region:         providerInfo.Region,
-			AuthorizedKeys: []string{sshutils.GetLocalPublicSSHKey()},
+			Region:         region,
+			AuthorizedKeys: []string{fleetPublicKey(l.Configs, fleetName)},
 			Booted:         &booted,
 			Label:          name,
+			Tags:           append([]string{utils.FleetTag(fleetName)}, providerInfo.Tags...),
+			Metadata:       metadata,
 		})
 
 		if err != nil {
@@ -203,6 +244,11 @@ func (l LinodeService) spawnBox(name string) error {
 	return nil
 }
 
+// DeleteSSHKey does nothing, Linode boxes get the fleet key inline
+func (l LinodeService) DeleteSSHKey(fleetName string) error {
+	return nil
+}
+
 func (l LinodeService) DeleteFleet(name string) error {
 	// TODO manage error
 	boxes, err := l.GetBoxes()
@@ -246,7 +292,7 @@ func (l LinodeService) DeleteFleet(name string) error {
 	}
 
 	for i := range boxes {
-		if utils.MatchesFleetName(boxes[i].Label, name) {
+		if inFleet(boxes[i], name, l.Configs) {
 			fleet <- &boxes[i]
 		}
 	}
@@ -283,7 +329,7 @@ func (l LinodeService) DeleteBoxByLabel(label string) error {
 
 func (l LinodeService) CountFleet(fleetName string, boxes []provider.Box) (count int) {
 	for _, box := range boxes {
-		if utils.MatchesFleetName(box.Label, fleetName) {
+		if inFleet(box, fleetName, l.Configs) {
 			count++
 		}
 	}
@@ -299,7 +345,7 @@ func (l LinodeService) RunCommand(name, command string, port int, username, pass
 	for _, box := range boxes {
 		if box.Label == name {
 			// It's a single box
-			sshutils.RunCommand(command, box.IP, port, username, l.Configs.SSHKeys.PrivateFile)
+			l.Dialer.RunCommand(command, box.IP, port, username, boxPrivateKey(box, l.Configs))
 			return nil
 		}
 	}
@@ -319,14 +365,14 @@ func (l LinodeService) RunCommand(name, command string, port int, username, pass
 				if box == nil {
 					break
 				}
-				sshutils.RunCommand(command, box.IP, port, username, l.Configs.SSHKeys.PrivateFile)
+				l.Dialer.RunCommand(command, box.IP, port, username, boxPrivateKey(*box, l.Configs))
 			}
 			processGroup.Done()
 		}()
 	}
 
 	for i := range boxes {
-		if utils.MatchesFleetName(boxes[i].Label, name) {
+		if inFleet(boxes[i], name, l.Configs) {
 			fleet <- &boxes[i]
 		}
 	}
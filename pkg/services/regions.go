@@ -0,0 +1,172 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/provider"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+	"github.com/digitalocean/godo"
+	"github.com/linode/linodego"
+)
+
+// capacityErrors are the messages returned by providers when a region can't
+// take the requested size. Account wide limits are left out, another region
+// would fail the same way.
+var capacityErrors = []string{
+	// DigitalOcean (422)
+	"is not available in this region",
+	"is not available in the selected region",
+	"region is currently unavailable",
+	// Linode (400)
+	"is not available in region",
+	"no capacity available",
+	"out of stock",
+	// Vultr (400)
+	"is sold out",
+	"plan is not available in the selected location",
+	"location is currently unavailable",
+}
+
+// errorStatus returns the HTTP status of a provider API error, 0 if unknown
+func errorStatus(err error) int {
+	var doErr *godo.ErrorResponse
+	if errors.As(err, &doErr) && doErr.Response != nil {
+		return doErr.Response.StatusCode
+	}
+	var linodeErr *linodego.Error
+	if errors.As(err, &linodeErr) {
+		return linodeErr.Code
+	}
+	// govultr returns the response body as the error message
+	var vultrErr struct {
+		Status int `json:"status"`
+	}
+	if json.Unmarshal([]byte(err.Error()), &vultrErr) == nil {
+		return vultrErr.Status
+	}
+	return 0
+}
+
+// isTransientError reports whether err is a rate limit or a server error,
+// which says nothing about the region
+func isTransientError(err error) bool {
+	status := errorStatus(err)
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func isCapacityError(err error) bool {
+	if isTransientError(err) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range capacityErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// regionPlacer picks the region of each new box of a fleet, following the
+// region strategy of the provider and skipping regions that failed
+type regionPlacer struct {
+	mu       sync.Mutex
+	regions  []string
+	strategy string
+	max      int
+	counts   map[string]int
+	failed   map[string]bool
+	// lastErr is the error that made the last region fail
+	lastErr error
+}
+
+// newRegionPlacer counts the boxes the fleet already has in each region
+func newRegionPlacer(info models.Provider, fleet []provider.Box) *regionPlacer {
+	r := &regionPlacer{
+		regions:  info.RegionList(),
+		strategy: info.RegionStrategy,
+		max:      info.MaxPerRegion,
+		counts:   make(map[string]int),
+		failed:   make(map[string]bool),
+	}
+	for _, box := range fleet {
+		r.counts[box.Region]++
+	}
+	return r
+}
+
+// next reserves a region for a new box
+func (r *regionPlacer) next() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	best := ""
+	found := false
+	for _, region := range r.regions {
+		if r.failed[region] || (r.max > 0 && r.counts[region] >= r.max) {
+			continue
+		}
+		if r.strategy == models.RegionFill {
+			best, found = region, true
+			break
+		}
+		if !found || r.counts[region] < r.counts[best] {
+			best, found = region, true
+		}
+	}
+	if !found {
+		if r.lastErr != nil {
+			return "", fmt.Errorf("no region left to spawn in (tried %s): %w", strings.Join(r.regions, ", "), r.lastErr)
+		}
+		return "", fmt.Errorf("no region left to spawn in (tried %s)", strings.Join(r.regions, ", "))
+	}
+
+	r.counts[best]++
+	return best, nil
+}
+
+// release gives back n regions reserved by next. If err is a capacity or
+// quota error the region is not used again, and release reports whether the
+// boxes can be retried elsewhere.
+func (r *regionPlacer) release(region string, n int, err error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counts[region] -= n
+	if len(r.regions) < 2 || !isCapacityError(err) {
+		return false
+	}
+	utils.Log.Warnf("Region %s unavailable, falling back to another region: %v", region, err)
+	r.failed[region] = true
+	r.lastErr = err
+	return true
+}
+
+// spawnInRegions creates a box in the region picked by placer, moving to the
+// next region on capacity errors
+func spawnInRegions(placer *regionPlacer, name string, spawn func(region string) error) error {
+	for {
+		region, err := placer.next()
+		if err != nil {
+			return err
+		}
+		if region != "" {
+			utils.Log.Info("Spawning box ", name, " in ", region)
+		} else {
+			utils.Log.Info("Spawning box ", name)
+		}
+		err = spawn(region)
+		if err == nil {
+			return nil
+		}
+		if !placer.release(region, 1, err) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"github.com/FleexSecurity/fleex/pkg/utils"
+)
+
+// legacyDigitaloceanUserData is what DigitalOcean boxes ran at boot before
+// user_data templates, only used with "user_data": "legacy"
+const legacyDigitaloceanUserData = `#!/bin/bash
+sudo sed -i "/^[^#]*PasswordAuthentication[[:space:]]no/c\PasswordAuthentication yes" /etc/ssh/sshd_config
+sudo service sshd restart
+echo 'op:{vars.PASSWORD}' | sudo chpasswd
+home=$(getent passwd {vars.USERNAME} | cut -d: -f6)
+if [ -n "$home" ]; then
+  mkdir -p "$home/.ssh"
+  echo '{vars.PUBLIC_KEY}' >> "$home/.ssh/authorized_keys"
+  chown -R {vars.USERNAME} "$home/.ssh"
+  chmod 700 "$home/.ssh"
+  chmod 600 "$home/.ssh/authorized_keys"
+fi`
+
+// userDataTemplate loads the user_data template of the provider, fallback if
+// none is configured
+func userDataTemplate(info models.Provider, fallback string) (string, error) {
+	if info.UserData == "" {
+		return fallback, nil
+	}
+	return utils.ReadUserDataTemplate(info.UserData)
+}
+
+// digitaloceanUserData returns the template DigitalOcean boxes boot with,
+// hardened unless the legacy script is asked for
+func digitaloceanUserData(info models.Provider) (string, error) {
+	if info.UserData == utils.UserDataLegacy {
+		return legacyDigitaloceanUserData, nil
+	}
+	return userDataTemplate(info, utils.HardenedUserData)
+}
+
+// InstallsFleetKey reports whether boxes of the selected provider will accept
+// the fleet key for the configured username. Providers only install it for
+// root, other users need a user_data template adding {vars.PUBLIC_KEY}.
+func InstallsFleetKey(configs *models.Config) bool {
+	providerName := strings.ToLower(configs.Settings.Provider)
+	info := configs.Providers[configs.Settings.Provider]
+	if info.Username == "root" {
+		return true
+	}
+
+	var template string
+	var err error
+	if providerName == "digitalocean" {
+		template, err = digitaloceanUserData(info)
+	} else {
+		template, err = userDataTemplate(info, "")
+	}
+	return err == nil && strings.Contains(template, "{vars.PUBLIC_KEY}")
+}
+
+// renderUserData fills a user_data template for a new box. Every variable
+// of user_data_vars is available next to the fleet and box ones.
+func renderUserData(template string, configs *models.Config, fleetName, boxName, password string) (string, error) {
+	if template == "" {
+		return "", nil
+	}
+	info := configs.Providers[configs.Settings.Provider]
+
+	index := ""
+	if i := strings.LastIndex(boxName, "-"); i >= 0 {
+		index = boxName[i+1:]
+	}
+	publicKey := ""
+	if utils.FleetSSHKeys(fleetName, configs.SSHKeys).PublicFile != "" {
+		publicKey = fleetPublicKey(configs, fleetName)
+	}
+
+	vars := map[string]string{
+		"FLEET_NAME": fleetName,
+		"BOX_NAME":   boxName,
+		"INDEX":      index,
+		"PUBLIC_KEY": publicKey,
+		"USERNAME":   info.Username,
+		"PASSWORD":   password,
+		"TTL":        "",
+	}
+	for k, v := range info.UserDataVars {
+		vars[k] = v
+	}
+	return utils.ReplaceBuildVars(template, vars)
+}
+
+// encodeUserData base64 encodes user_data for the APIs that require it
+func encodeUserData(userData string) string {
+	if userData == "" {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(userData))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/FleexSecurity/fleex/pkg/models"
@@ -17,48 +18,65 @@ import (
 type VultrService struct {
 	Client  *govultr.Client
 	Configs *models.Config
+	Dialer  *sshutils.Dialer
 }
 
 func (v VultrService) SpawnFleet(fleetName string, fleetCount int) error {
-	existingFleet, _ := v.GetFleet(fleetName)
+	existingBoxes, _ := v.GetBoxes()
+	return v.createBoxes(existingBoxes, fleetName, newBoxNames(existingBoxes, fleetName, fleetCount))
+}
+
+// SpawnBoxes creates boxes with the given labels in the fleet
+func (v VultrService) SpawnBoxes(fleetName string, labels []string) error {
+	existingBoxes, _ := v.GetBoxes()
+	return v.createBoxes(existingBoxes, fleetName, labels)
+}
+
+func (v VultrService) createBoxes(existingBoxes []provider.Box, fleetName string, names []string) error {
 	providerName := v.Configs.Settings.Provider
 	providerInfo := v.Configs.Providers[providerName]
+	placer := newRegionPlacer(providerInfo, fleetBoxes(existingBoxes, fleetName, v.Configs))
 
 	image := providerInfo.Image
-	region := providerInfo.Region
 	size := providerInfo.Size
+	template, err := userDataTemplate(providerInfo, "")
+	if err != nil {
+		return err
+	}
+	sshKey := v.getSSHKey(fleetName)
 
 	threads := 10
 	fleet := make(chan string, threads)
 	processGroup := new(sync.WaitGroup)
-	processGroup.Add(threads)
+	errChan := make(chan error, threads)
 
 	for i := 0; i < threads; i++ {
-		go func() error {
-			for {
-				box := <-fleet
-
-				if box == "" {
-					break
-				}
-
-				utils.Log.Info("Spawning box ", box)
-				err := v.spawnBox(box, image, region, size)
+		processGroup.Add(1)
+		go func() {
+			defer processGroup.Done()
+			for box := range fleet {
+				err := spawnInRegions(placer, box, func(region string) error {
+					return v.spawnBox(box, fleetName, image, region, size, template, sshKey)
+				})
 				if err != nil {
-					return err
+					errChan <- err
+					return
 				}
 			}
-			processGroup.Done()
-			return nil
 		}()
 	}
 
-	for i := 0; i < fleetCount; i++ {
-		fleet <- fleetName + "-" + strconv.Itoa(i+1+len(existingFleet))
+	for _, name := range names {
+		fleet <- name
 	}
 
 	close(fleet)
 	processGroup.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		return err
+	}
 	return nil
 }
 
@@ -77,6 +95,10 @@ func (v VultrService) GetBoxes() (boxes []provider.Box, err error) {
 				Label:  instance.Label,
 				Status: string(instance.Status),
 				IP:     instance.MainIP,
+				Size:   instance.Plan,
+				CPUs:   instance.VCPUCount,
+				Tags:   instance.Tags,
+				Region: instance.Region,
 			})
 		}
 		if meta.Links.Next == "" {
@@ -97,7 +119,7 @@ func (v VultrService) GetFleet(fleetName string) (fleet []provider.Box, err erro
 	}
 
 	for _, box := range boxes {
-		if utils.MatchesFleetName(box.Label, fleetName) {
+		if inFleet(box, fleetName, v.Configs) {
 			fleet = append(fleet, box)
 		}
 	}
@@ -215,7 +237,7 @@ func (v VultrService) DeleteFleet(name string) error {
 	}
 
 	for i := range boxes {
-		if utils.MatchesFleetName(boxes[i].Label, name) {
+		if inFleet(boxes[i], name, v.Configs) {
 			fleet <- &boxes[i]
 		}
 	}
@@ -255,11 +277,9 @@ func (v VultrService) RunCommand(name, command string, port int, username, passw
 		return err
 	}
 
-	privateKey := v.Configs.SSHKeys.PrivateFile
-
 	for _, box := range boxes {
 		if box.Label == name {
-			sshutils.RunCommand(command, box.IP, port, username, privateKey)
+			v.Dialer.RunCommand(command, box.IP, port, username, boxPrivateKey(box, v.Configs))
 			return nil
 		}
 	}
@@ -278,14 +298,14 @@ func (v VultrService) RunCommand(name, command string, port int, username, passw
 				if box == nil {
 					break
 				}
-				sshutils.RunCommand(command, box.IP, port, username, privateKey)
+				v.Dialer.RunCommand(command, box.IP, port, username, boxPrivateKey(*box, v.Configs))
 			}
 			processGroup.Done()
 		}()
 	}
 
 	for i := range boxes {
-		if utils.MatchesFleetName(boxes[i].Label, name) {
+		if inFleet(boxes[i], name, v.Configs) {
 			fleet <- &boxes[i]
 		}
 	}
@@ -297,15 +317,20 @@ func (v VultrService) RunCommand(name, command string, port int, username, passw
 
 func (v VultrService) CountFleet(fleetName string, boxes []provider.Box) (count int) {
 	for _, box := range boxes {
-		if utils.MatchesFleetName(box.Label, fleetName) {
+		if inFleet(box, fleetName, v.Configs) {
 			count++
 		}
 	}
 	return count
 }
 
-func (v VultrService) spawnBox(name string, image string, region string, size string) error {
-	sshKey := v.getSSHKey()
+func (v VultrService) spawnBox(name, fleetName string, image string, region string, size string, template string, sshKey string) error {
+	providerInfo := v.Configs.Providers[v.Configs.Settings.Provider]
+	tags := append([]string{utils.FleetTag(fleetName)}, providerInfo.Tags...)
+	userData, err := renderUserData(template, v.Configs, fleetName, name, providerInfo.Password)
+	if err != nil {
+		return fmt.Errorf("invalid user_data template: %w", err)
+	}
 	instanceOptions := &govultr.InstanceCreateReq{}
 
 	os_id, err := strconv.Atoi(image)
@@ -318,6 +343,8 @@ func (v VultrService) spawnBox(name string, image string, region string, size st
 			Hostname: name,
 			SSHKeys:  []string{sshKey},
 			Backups:  "disabled",
+			Tags:     tags,
+			UserData: encodeUserData(userData),
 		}
 		if err != nil {
 			return err
@@ -331,11 +358,16 @@ func (v VultrService) spawnBox(name string, image string, region string, size st
 			SnapshotID: image,
 			SSHKeys:    []string{sshKey},
 			Backups:    "disabled",
+			Tags:       tags,
+			UserData:   encodeUserData(userData),
 		}
 	}
 	_, err = v.Client.Instance.Create(context.Background(), instanceOptions)
 
 	if err != nil {
+		if isCapacityError(err) || isTransientError(err) {
+			return err
+		}
 		return models.ErrInvalidImage
 	}
 	return nil
@@ -353,12 +385,12 @@ func (v VultrService) CreateImage(diskID int, label string) error {
 	return nil
 }
 
-func (v VultrService) getSSHKey() string {
-	fleex_key := sshutils.GetLocalPublicSSHKey()
+func (v VultrService) getSSHKey(fleetName string) string {
+	fleex_key := fleetPublicKey(v.Configs, fleetName)
 	keyID := v.KeyCheck(fleex_key)
 	if keyID == "" {
 		sshkeyOptions := &govultr.SSHKeyReq{
-			Name:   "fleex_key",
+			Name:   fleetKeyName(fleetName),
 			SSHKey: fleex_key,
 		}
 		_, err := v.Client.SSHKey.Create(context.Background(), sshkeyOptions)
@@ -372,7 +404,6 @@ func (v VultrService) getSSHKey() string {
 
 func (v VultrService) KeyCheck(fleex_key string) string {
 	listOptions := &govultr.ListOptions{PerPage: 100}
-	var keyID string
 	for {
 		keys, meta, err := v.Client.SSHKey.List(context.Background(), listOptions)
 
@@ -380,10 +411,8 @@ func (v VultrService) KeyCheck(fleex_key string) string {
 			utils.Log.Fatal(err)
 		}
 		for _, key := range keys {
-			if fleex_key == key.SSHKey {
-				keyID = key.ID
-			} else {
-				keyID = ""
+			if strings.TrimSpace(key.SSHKey) == fleex_key {
+				return key.ID
 			}
 		}
 		if meta.Links.Next == "" {
@@ -393,7 +422,29 @@ func (v VultrService) KeyCheck(fleex_key string) string {
 			continue
 		}
 	}
-	return keyID
+	return ""
+}
+
+// DeleteSSHKey removes the key registered for the fleet
+func (v VultrService) DeleteSSHKey(fleetName string) error {
+	listOptions := &govultr.ListOptions{PerPage: 100}
+	for {
+		keys, meta, err := v.Client.SSHKey.List(context.Background(), listOptions)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if key.Name == "fleex-"+fleetName {
+				if err := v.Client.SSHKey.Delete(context.Background(), key.ID); err != nil {
+					return err
+				}
+			}
+		}
+		if meta.Links.Next == "" {
+			return nil
+		}
+		listOptions.Cursor = meta.Links.Next
+	}
 }
 
 func (v VultrService) TransferImage(imageID int, region string) error {
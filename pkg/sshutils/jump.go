@@ -0,0 +1,200 @@
+package sshutils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"golang.org/x/crypto/ssh"
+)
+
+// JumpHost is a bastion a connection goes through, like an OpenSSH ProxyJump
+// entry
+type JumpHost struct {
+	User    string
+	Host    string
+	Port    int
+	KeyFile string
+}
+
+func (j JumpHost) addr() string {
+	return net.JoinHostPort(j.Host, strconv.Itoa(j.Port))
+}
+
+// ParseProxyJump parses a comma separated list of [user@]host[:port] jump
+// hosts, authenticated with keyFile. The user defaults to the local one and
+// the port to 22. "none" and an empty spec mean a direct connection.
+func ParseProxyJump(spec, keyFile string) ([]JumpHost, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "none" {
+		return nil, nil
+	}
+
+	var jumps []JumpHost
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		jump := JumpHost{Port: 22, KeyFile: keyFile}
+
+		if i := strings.LastIndex(entry, "@"); i >= 0 {
+			jump.User = entry[:i]
+			entry = entry[i+1:]
+		} else if u, err := user.Current(); err == nil {
+			jump.User = u.Username
+		}
+
+		jump.Host = entry
+		if host, port, err := net.SplitHostPort(entry); err == nil {
+			p, err := strconv.Atoi(port)
+			if err != nil || p <= 0 || p > 65535 {
+				return nil, fmt.Errorf("invalid jump host port: %s", entry)
+			}
+			jump.Host, jump.Port = host, p
+		}
+		if jump.Host == "" || jump.User == "" {
+			return nil, fmt.Errorf("invalid jump host: %s", entry)
+		}
+		jumps = append(jumps, jump)
+	}
+	return jumps, nil
+}
+
+// Dialer opens SSH connections, through the jump hosts configured for the
+// host. A nil Dialer connects directly.
+type Dialer struct {
+	// Jumps is the chain of every host without an entry in Hosts
+	Jumps []JumpHost
+	// Hosts overrides the chain of some hosts, nil to connect directly
+	Hosts map[string][]JumpHost
+}
+
+// NewDialer returns the dialer of the selected provider, with the custom VMs
+// overriding its jump hosts
+func NewDialer(configs *models.Config) (*Dialer, error) {
+	name := configs.Settings.Provider
+	providerInfo := configs.Providers[name]
+	jumps, err := ParseProxyJump(providerInfo.ProxyJump, proxyJumpKey(providerInfo.ProxyJumpKey, configs))
+	if err != nil {
+		return nil, fmt.Errorf("providers.%s.proxy_jump: %v", name, err)
+	}
+
+	d := &Dialer{Jumps: jumps, Hosts: make(map[string][]JumpHost)}
+	for _, vm := range configs.CustomVMs {
+		if vm.ProxyJump == "" {
+			continue
+		}
+		jumps, err := ParseProxyJump(vm.ProxyJump, proxyJumpKey(vm.ProxyJumpKey, configs))
+		if err != nil {
+			return nil, fmt.Errorf("custom_vms.%s.proxy_jump: %v", vm.InstanceID, err)
+		}
+		d.Hosts[vm.PublicIP] = jumps
+	}
+	return d, nil
+}
+
+// proxyJumpKey returns the key of the jump hosts, the shared ssh_keys one by
+// default
+func proxyJumpKey(keyPath string, configs *models.Config) string {
+	if keyPath != "" {
+		return keyPath
+	}
+	return configs.SSHKeys.PrivateFile
+}
+
+func (d *Dialer) jumpsFor(addr string) []JumpHost {
+	if d == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if jumps, ok := d.Hosts[host]; ok {
+		return jumps
+	}
+	return d.Jumps
+}
+
+// Dial opens an SSH connection to addr through its jump hosts, if any. The
+// jump host connections are closed along with the returned client.
+func (d *Dialer) Dial(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	jumps := d.jumpsFor(addr)
+	if len(jumps) == 0 {
+		return ssh.Dial("tcp", addr, config)
+	}
+
+	var hops []*ssh.Client
+	closeHops := func() {
+		for i := len(hops) - 1; i >= 0; i-- {
+			hops[i].Close()
+		}
+	}
+
+	for i, jump := range jumps {
+		jumpConfig, err := jumpClientConfig(jump, config)
+		if err != nil {
+			closeHops()
+			return nil, err
+		}
+		client, err := dialHop(hops, jump.addr(), jumpConfig)
+		if err != nil {
+			closeHops()
+			return nil, fmt.Errorf("jump host %d (%s): %w", i+1, jump.addr(), err)
+		}
+		hops = append(hops, client)
+	}
+
+	client, err := dialHop(hops, addr, config)
+	if err != nil {
+		closeHops()
+		return nil, err
+	}
+	go func() {
+		client.Wait()
+		closeHops()
+	}()
+	return client, nil
+}
+
+// dialHop connects to addr through the last hop, or directly for the first
+func dialHop(hops []*ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	if len(hops) == 0 {
+		return ssh.Dial("tcp", addr, config)
+	}
+	conn, err := hops[len(hops)-1].Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// jumpClientConfig authenticates to a jump host with its key, or with the
+// auth methods of the target when it has none
+func jumpClientConfig(jump JumpHost, target *ssh.ClientConfig) (*ssh.ClientConfig, error) {
+	auth := target.Auth
+	if jump.KeyFile != "" {
+		key, err := os.ReadFile(jump.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", jump.KeyFile, err)
+		}
+		auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	}
+	return &ssh.ClientConfig{
+		User:            jump.User,
+		Auth:            auth,
+		HostKeyCallback: target.HostKeyCallback,
+		Timeout:         target.Timeout,
+	}, nil
+}
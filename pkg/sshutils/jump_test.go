@@ -0,0 +1,316 @@
+package sshutils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseProxyJump(t *testing.T) {
+	const key = "/keys/id"
+
+	tests := []struct {
+		spec    string
+		want    []JumpHost
+		wantErr bool
+	}{
+		{spec: "", want: nil},
+		{spec: "none", want: nil},
+		{spec: "  none  ", want: nil},
+		{spec: "ops@bastion:2222", want: []JumpHost{{User: "ops", Host: "bastion", Port: 2222, KeyFile: key}}},
+		{spec: "ops@bastion", want: []JumpHost{{User: "ops", Host: "bastion", Port: 22, KeyFile: key}}},
+		{spec: "ops@10.0.0.1:22, root@10.0.1.1:2200", want: []JumpHost{
+			{User: "ops", Host: "10.0.0.1", Port: 22, KeyFile: key},
+			{User: "root", Host: "10.0.1.1", Port: 2200, KeyFile: key},
+		}},
+		{spec: "ops@[::1]:2222", want: []JumpHost{{User: "ops", Host: "::1", Port: 2222, KeyFile: key}}},
+		{spec: "ops@bastion:ssh", wantErr: true},
+		{spec: "ops@bastion:70000", wantErr: true},
+		{spec: "ops@", wantErr: true},
+		{spec: "ops@:22", wantErr: true},
+		{spec: "@bastion", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseProxyJump(tt.spec, key)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseProxyJump(%q) = %+v, want an error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseProxyJump(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseProxyJump(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseProxyJumpDefaultUser(t *testing.T) {
+	got, err := ParseProxyJump("bastion:2222", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].User == "" || got[0].Host != "bastion" || got[0].Port != 2222 {
+		t.Errorf("got %+v, want the local user on bastion:2222", got)
+	}
+}
+
+// testServer is an in-process SSH server accepting a single key. Jump
+// servers forward direct-tcpip channels, targets answer exec requests with
+// the name of the server.
+type testServer struct {
+	name string
+	addr string
+
+	mu    sync.Mutex
+	users []string
+}
+
+func (s *testServer) logins() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.users...)
+}
+
+func startTestServer(t *testing.T, name string, clientKey ssh.PublicKey) *testServer {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{name: name}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			s.mu.Lock()
+			s.users = append(s.users, meta.User())
+			s.mu.Unlock()
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	s.addr = listener.Addr().String()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		switch newChan.ChannelType() {
+		case "direct-tcpip":
+			go forward(newChan)
+		case "session":
+			go s.session(newChan)
+		default:
+			newChan.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+func forward(newChan ssh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChan.ExtraData(), &target); err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChan.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		io.Copy(conn, channel)
+		conn.Close()
+	}()
+	io.Copy(channel, conn)
+	channel.Close()
+}
+
+func (s *testServer) session(newChan ssh.NewChannel) {
+	channel, reqs, err := newChan.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		channel.Write([]byte(s.name))
+		status := make([]byte, 4)
+		binary.BigEndian.PutUint32(status, 0)
+		channel.SendRequest("exit-status", false, status)
+		return
+	}
+}
+
+// writeTestKey writes a new OpenSSH private key and returns its path
+func writeTestKey(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, sshPublicKey
+}
+
+func splitAddr(t *testing.T, addr string) (string, int) {
+	t.Helper()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, p
+}
+
+func TestDialerChain(t *testing.T) {
+	keyPath, publicKey := writeTestKey(t)
+	first := startTestServer(t, "first", publicKey)
+	second := startTestServer(t, "second", publicKey)
+	target := startTestServer(t, "target", publicKey)
+	targetHost, targetPort := splitAddr(t, target.addr)
+
+	jumps, err := ParseProxyJump("jump1@"+first.addr+",jump2@"+second.addr, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("through two jump hosts", func(t *testing.T) {
+		d := &Dialer{Jumps: jumps}
+		out, err := d.RunCommandWithOutput("hostname", targetHost, targetPort, "box", keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "target" {
+			t.Errorf("output = %q, want target", out)
+		}
+		if got := first.logins(); !reflect.DeepEqual(got, []string{"jump1"}) {
+			t.Errorf("first jump logins = %v, want [jump1]", got)
+		}
+		if got := second.logins(); !reflect.DeepEqual(got, []string{"jump2"}) {
+			t.Errorf("second jump logins = %v, want [jump2]", got)
+		}
+		if got := target.logins(); !reflect.DeepEqual(got, []string{"box"}) {
+			t.Errorf("target logins = %v, want [box]", got)
+		}
+	})
+
+	t.Run("host override connects directly", func(t *testing.T) {
+		d := &Dialer{
+			Jumps: []JumpHost{{User: "nobody", Host: "127.0.0.1", Port: 1, KeyFile: keyPath}},
+			Hosts: map[string][]JumpHost{targetHost: nil},
+		}
+		out, err := d.RunCommandWithOutput("hostname", targetHost, targetPort, "box", keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "target" {
+			t.Errorf("output = %q, want target", out)
+		}
+	})
+
+	t.Run("nil dialer connects directly", func(t *testing.T) {
+		var d *Dialer
+		out, err := d.RunCommandWithOutput("hostname", targetHost, targetPort, "box", keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "target" {
+			t.Errorf("output = %q, want target", out)
+		}
+	})
+
+	t.Run("unreachable jump host", func(t *testing.T) {
+		// Grab a free port and release it so nothing listens there
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		closed := listener.Addr().String()
+		listener.Close()
+
+		down, err := ParseProxyJump("jump1@"+first.addr+",jump2@"+closed, keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &Dialer{Jumps: down}
+		if _, err := d.RunCommandWithOutput("hostname", targetHost, targetPort, "box", keyPath); err == nil {
+			t.Error("connected through an unreachable jump host")
+		}
+	})
+}
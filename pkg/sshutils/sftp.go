@@ -0,0 +1,269 @@
+package sshutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+)
+
+// partSuffix marks files being transferred. An interrupted transfer leaves
+// the part file behind and the next one resumes from its size, if the source
+// recorded next to it with partSourceSuffix is unchanged.
+const (
+	partSuffix       = ".fleex-part"
+	partSourceSuffix = ".src"
+)
+
+// partSource identifies the version of a source file a part was started from
+func partSource(info os.FileInfo) string {
+	return fmt.Sprintf("%d %d", info.Size(), info.ModTime().Unix())
+}
+
+// resumeOffset returns where to resume writing a part file, 0 when it was
+// started from another source
+func resumeOffset(partSize int64, recorded string, source os.FileInfo) int64 {
+	if recorded != partSource(source) || partSize > source.Size() {
+		return 0
+	}
+	return partSize
+}
+
+// sftpBackend uses the SFTP subsystem of the box, so it works without an scp
+// binary and can resume partial transfers
+type sftpBackend struct {
+	client *sftp.Client
+}
+
+func newSFTPBackend(conn *Connection) (*sftpBackend, error) {
+	client, err := sftp.NewClient(conn.Client)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpBackend{client: client}, nil
+}
+
+func (b *sftpBackend) sendFile(localPath, remotePath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	partPath := remotePath + partSuffix
+	sourcePath := partPath + partSourceSuffix
+	var offset int64
+	if partInfo, err := b.client.Stat(partPath); err == nil {
+		offset = resumeOffset(partInfo.Size(), b.readRemote(sourcePath), info)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+		if err := b.writeRemote(sourcePath, partSource(info)); err != nil {
+			return err
+		}
+	}
+	dst, err := b.client.OpenFile(partPath, flags)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Seek(offset, io.SeekStart); err != nil {
+		dst.Close()
+		return err
+	}
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		dst.Close()
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	if err := b.client.Chmod(partPath, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := b.client.Chtimes(partPath, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	if err := b.rename(partPath, remotePath); err != nil {
+		return err
+	}
+	b.client.Remove(sourcePath)
+	return nil
+}
+
+func (b *sftpBackend) receiveFile(remotePath, localPath string) error {
+	src, err := b.client.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	partPath := localPath + partSuffix
+	sourcePath := partPath + partSourceSuffix
+	var offset int64
+	if partInfo, err := os.Stat(partPath); err == nil {
+		recorded, _ := os.ReadFile(sourcePath)
+		offset = resumeOffset(partInfo.Size(), string(recorded), info)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+		if err := os.WriteFile(sourcePath, []byte(partSource(info)), 0644); err != nil {
+			return err
+		}
+	}
+	dst, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Seek(offset, io.SeekStart); err != nil {
+		dst.Close()
+		return err
+	}
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		dst.Close()
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(partPath, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(partPath, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, localPath); err != nil {
+		return err
+	}
+	os.Remove(sourcePath)
+	return nil
+}
+
+// sendDir copies into remotePath/<dir> when remotePath exists, like scp -r
+func (b *sftpBackend) sendDir(localDir, remotePath string, progress ProgressFunc) error {
+	if info, err := b.client.Stat(remotePath); err == nil && info.IsDir() {
+		remotePath = path.Join(remotePath, filepath.Base(localDir))
+	}
+
+	return filepath.Walk(localDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		target := path.Join(remotePath, filepath.ToSlash(rel))
+
+		if info.IsDir() {
+			if err := b.client.MkdirAll(target); err != nil {
+				return err
+			}
+			return b.client.Chmod(target, info.Mode().Perm())
+		}
+		if progress != nil {
+			progress(localPath, info.Size())
+		}
+		return b.sendFile(localPath, target)
+	})
+}
+
+// receiveDir copies into localDir/<dir> when localDir exists, like scp -r
+func (b *sftpBackend) receiveDir(remotePath, localDir string, progress ProgressFunc) error {
+	if info, err := os.Stat(localDir); err == nil && info.IsDir() {
+		localDir = filepath.Join(localDir, path.Base(remotePath))
+	}
+
+	walker := b.client.Walk(remotePath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(remotePath, walker.Path())
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(localDir, rel)
+		info := walker.Stat()
+
+		if info.IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			continue
+		}
+		if progress != nil {
+			progress(target, info.Size())
+		}
+		if err := b.receiveFile(walker.Path(), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rename replaces newPath, falling back to remove and rename on servers
+// without the posix-rename extension
+func (b *sftpBackend) rename(oldPath, newPath string) error {
+	if err := b.client.PosixRename(oldPath, newPath); err == nil {
+		return nil
+	}
+	b.client.Remove(newPath)
+	return b.client.Rename(oldPath, newPath)
+}
+
+// readRemote returns the content of a small remote file, empty if missing
+func (b *sftpBackend) readRemote(remotePath string) string {
+	file, err := b.client.Open(remotePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	data, _ := io.ReadAll(file)
+	return string(data)
+}
+
+func (b *sftpBackend) writeRemote(remotePath, content string) error {
+	file, err := b.client.Create(remotePath)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write([]byte(content)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (b *sftpBackend) close() error {
+	return b.client.Close()
+}
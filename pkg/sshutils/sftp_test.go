@@ -0,0 +1,131 @@
+package sshutils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// newPipeBackend returns an sftp backend served in-process on the local
+// filesystem
+func newPipeBackend(t *testing.T) *sftpBackend {
+	t.Helper()
+	serverRead, clientWrite := io.Pipe()
+	clientRead, serverWrite := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverRead, serverWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientRead, clientWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return &sftpBackend{client: client}
+}
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSFTPResume(t *testing.T) {
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name string
+		// part left by an interrupted transfer, and the source it recorded
+		part, partSource string
+		want             string
+	}{
+		{
+			name:       "resumes the same source",
+			part:       "new ",
+			partSource: partSource(fakeInfo{size: 12, modTime: modTime}),
+			want:       "new contents",
+		},
+		{
+			name:       "truncates a part of another source",
+			part:       "old ",
+			partSource: partSource(fakeInfo{size: 12, modTime: modTime.Add(-time.Hour)}),
+			want:       "new contents",
+		},
+		{
+			name: "truncates a part without source",
+			part: "old ",
+			want: "new contents",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, direction := range []string{"send", "receive"} {
+			t.Run(tt.name+"/"+direction, func(t *testing.T) {
+				b := newPipeBackend(t)
+				dir := t.TempDir()
+				src := filepath.Join(dir, "src.txt")
+				dst := filepath.Join(dir, "dst.txt")
+				writeFile(t, src, "new contents", modTime)
+
+				// A resumed part must not be read again, prove it by
+				// corrupting the source prefix when it should be kept
+				if tt.part == "new " {
+					writeFile(t, src, "XXX contents", modTime)
+				}
+				writeFile(t, dst+partSuffix, tt.part, modTime)
+				if tt.partSource != "" {
+					writeFile(t, dst+partSuffix+partSourceSuffix, tt.partSource, modTime)
+				}
+
+				var err error
+				if direction == "send" {
+					err = b.sendFile(src, dst)
+				} else {
+					err = b.receiveFile(src, dst)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := os.ReadFile(dst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+				for _, leftover := range []string{dst + partSuffix, dst + partSuffix + partSourceSuffix} {
+					if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+						t.Errorf("%s left behind", filepath.Base(leftover))
+					}
+				}
+			})
+		}
+	}
+}
+
+type fakeInfo struct {
+	os.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+func (f fakeInfo) Size() int64        { return f.size }
+func (f fakeInfo) ModTime() time.Time { return f.modTime }
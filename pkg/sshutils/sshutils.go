@@ -1,6 +1,8 @@
 package sshutils
 
 import (
+	"bufio"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -15,6 +17,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/FleexSecurity/fleex/pkg/models"
@@ -77,7 +80,7 @@ func SSHFingerprintGen(publicSSH string) string {
 	return f
 }
 
-func RunCommand(command string, ip string, port int, username string, privateKey string) *Connection {
+func (d *Dialer) RunCommand(command string, ip string, port int, username string, privateKey string) *Connection {
 	const maxRetries = 10
 	const retryInterval = 5 * time.Second
 
@@ -86,7 +89,7 @@ func RunCommand(command string, ip string, port int, username string, privateKey
 	addr := ip + ":" + strconv.Itoa(port)
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		conn, err = Connect(addr, username, privateKey)
+		conn, err = d.Connect(addr, username, privateKey)
 		if err == nil {
 			break
 		}
@@ -103,8 +106,8 @@ func RunCommand(command string, ip string, port int, username string, privateKey
 	return conn
 }
 
-func RunCommandSilent(command string, ip string, port int, username string, privateKey string) (*Connection, error) {
-	conn, err := Connect(ip+":"+strconv.Itoa(port), username, privateKey)
+func (d *Dialer) RunCommandSilent(command string, ip string, port int, username string, privateKey string) (*Connection, error) {
+	conn, err := d.Connect(ip+":"+strconv.Itoa(port), username, privateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -117,8 +120,8 @@ func RunCommandSilent(command string, ip string, port int, username string, priv
 	return conn, nil
 }
 
-func RunCommandWithOutput(command string, ip string, port int, username string, privateKey string) ([]byte, error) {
-	conn, err := Connect(ip+":"+strconv.Itoa(port), username, privateKey)
+func (d *Dialer) RunCommandWithOutput(command string, ip string, port int, username string, privateKey string) ([]byte, error) {
+	conn, err := d.Connect(ip+":"+strconv.Itoa(port), username, privateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -227,20 +230,20 @@ func setupStdPipes(session *ssh.Session) (io.WriteCloser, io.Reader, io.Reader,
 	return stdin, stdout, stderr, nil
 }
 
-func GetConnection(ip string, port int, username string, password string) (*Connection, error) {
-	conn, err := Connect(ip+":"+strconv.Itoa(port), username, password)
+func (d *Dialer) GetConnection(ip string, port int, username string, password string) (*Connection, error) {
+	conn, err := d.Connect(ip+":"+strconv.Itoa(port), username, password)
 	if err != nil {
 		return nil, fmt.Errorf("GetConnection: %v, IP: %s, Port: %d, Username: %s", err, ip, port, username)
 	}
 	return conn, nil
 }
 
-func GetConnectionBuild(ip string, port int, username string, password string) (*Connection, error) {
-	conn, err := Connect(ip+":"+strconv.Itoa(port), username, password)
+func (d *Dialer) GetConnectionBuild(ip string, port int, username string, password string) (*Connection, error) {
+	conn, err := d.Connect(ip+":"+strconv.Itoa(port), username, password)
 	return conn, err
 }
 
-func Connect(addr, username, sshKey string) (*Connection, error) {
+func (d *Dialer) Connect(addr, username, sshKey string) (*Connection, error) {
 	key, err := ioutil.ReadFile(sshKey)
 	if err != nil {
 		return nil, err
@@ -256,9 +259,10 @@ func Connect(addr, username, sshKey string) (*Connection, error) {
 			ssh.PublicKeys(signer),
 		},
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         30 * time.Second,
 	}
 
-	conn, err := ssh.Dial("tcp", addr, config)
+	conn, err := d.Dial(addr, config)
 	if err != nil {
 		return nil, err
 	}
@@ -274,6 +278,32 @@ func getHomeDir() string {
 	return usr.HomeDir
 }
 
+// GenerateEd25519KeyPair writes a new ed25519 keypair in OpenSSH format
+func GenerateEd25519KeyPair(comment string, keys models.SSHKeys) error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	privateKeyPEM, err := ssh.MarshalPrivateKey(privateKey, comment)
+	if err != nil {
+		return err
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keys.PrivateFile), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keys.PrivateFile, pem.EncodeToMemory(privateKeyPEM), 0600); err != nil {
+		return err
+	}
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey))) + " " + comment + "\n"
+	return os.WriteFile(keys.PublicFile, []byte(authorizedKey), 0644)
+}
+
 // Generate Key Pair
 func GenerateSSHKeyPair(bits int, email, path string) error {
 	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
@@ -328,3 +358,113 @@ func sshPublicKeyFromPrivateKey(privateKey *rsa.PrivateKey, email string) (strin
 
 	return fmt.Sprintf("%s %s", pubStr, email), nil
 }
+
+// Tail follows a remote file on an existing connection and calls onLine for
+// every complete line written to it, including the lines already there. The
+// file does not need to exist yet. stop ends the remote tail and waits for
+// the reader.
+func (conn *Connection) Tail(path string, onLine func(string)) (stop func(), err error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	// The shell prints its PID and becomes tail, so it can be killed later
+	if err := session.Start("echo $$; exec tail -n +1 -F " + path + " 2>/dev/null"); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(stdout)
+	pid, err := reader.ReadString('\n')
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	pid = strings.TrimSpace(pid)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			// A line without its newline may still be being written, the
+			// caller reads the file again once it is complete
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			onLine(strings.TrimRight(line, "\r\n"))
+		}
+	}()
+
+	stop = func() {
+		if kill, err := conn.NewSession(); err == nil {
+			kill.Run("kill " + pid)
+			kill.Close()
+		}
+		session.Close()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}
+	return stop, nil
+}
+
+// RunLines runs command without a PTY, calling onLine for every line written
+// to stdout or stderr, and returns the exit status of the command. onLine may
+// be called from two goroutines at once.
+func (conn *Connection) RunLines(command string, onLine func(line string, stderr bool)) (int, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return -1, err
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return -1, err
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return -1, err
+	}
+
+	if err := session.Start(command); err != nil {
+		return -1, err
+	}
+
+	var wg sync.WaitGroup
+	readLines := func(r io.Reader, isStderr bool) {
+		defer wg.Done()
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				onLine(strings.TrimRight(line, "\r\n"), isStderr)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go readLines(stdout, false)
+	go readLines(stderr, true)
+	wg.Wait()
+
+	err = session.Wait()
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		return exitErr.ExitStatus(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,427 @@
+package sshutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hnakamur/go-scp"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+)
+
+// Transferer moves files between the local machine and a box
+type Transferer interface {
+	// Upload copies a local file to remotePath
+	Upload(localPath, remotePath string) error
+	// Download copies remotePath to a local file
+	Download(remotePath, localPath string) error
+	// UploadDir recursively copies a local directory to remotePath
+	UploadDir(localDir, remotePath string, progress ProgressFunc) error
+	// DownloadDir recursively copies a remote directory to localDir
+	DownloadDir(remotePath, localDir string, progress ProgressFunc) error
+	Close() error
+}
+
+// backend copies raw files and directories over an SSH connection
+type backend interface {
+	sendFile(localPath, remotePath string) error
+	receiveFile(remotePath, localPath string) error
+	sendDir(localDir, remotePath string, progress ProgressFunc) error
+	receiveDir(remotePath, localDir string, progress ProgressFunc) error
+	close() error
+}
+
+// transferer adds compression, checksums and skipping of existing files on
+// top of a backend
+type transferer struct {
+	conn    *Connection
+	backend backend
+	opts    models.TransferSettings
+}
+
+// NewTransferer returns a Transferer using the given backend (scp when
+// empty) and transfer settings
+func (conn *Connection) NewTransferer(kind string, opts models.TransferSettings) (Transferer, error) {
+	t := &transferer{conn: conn, opts: opts}
+	switch kind {
+	case "", models.TransferSCP:
+		t.backend = &scpBackend{conn: conn}
+	case models.TransferSFTP:
+		b, err := newSFTPBackend(conn)
+		if err != nil {
+			return nil, err
+		}
+		t.backend = b
+	default:
+		return nil, fmt.Errorf("unknown transfer backend: %s", kind)
+	}
+	return t, nil
+}
+
+func (t *transferer) Upload(localPath, remotePath string) error {
+	var localHash string
+	if t.opts.Verify || t.opts.SkipExisting {
+		var err error
+		localHash, err = FileSHA256(localPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.opts.SkipExisting {
+		if remoteHash, err := t.conn.remoteSHA256(remotePath); err == nil && remoteHash == localHash {
+			return nil
+		}
+	}
+
+	var err error
+	if t.opts.Compression == "" {
+		err = t.backend.sendFile(localPath, remotePath)
+	} else {
+		err = t.conn.uploadCompressed(localPath, remotePath, t.opts.Compression)
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.opts.Verify {
+		remoteHash, err := t.conn.remoteSHA256(remotePath)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", remotePath, err)
+		}
+		if remoteHash != localHash {
+			return fmt.Errorf("checksum mismatch for %s", remotePath)
+		}
+	}
+	return nil
+}
+
+func (t *transferer) Download(remotePath, localPath string) error {
+	var remoteHash string
+	if t.opts.Verify || t.opts.SkipExisting {
+		var err error
+		remoteHash, err = t.conn.remoteSHA256(remotePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.opts.SkipExisting {
+		if localHash, err := FileSHA256(localPath); err == nil && localHash == remoteHash {
+			return nil
+		}
+	}
+
+	var err error
+	if t.opts.Compression == "" {
+		err = t.backend.receiveFile(remotePath, localPath)
+	} else {
+		err = t.conn.downloadCompressed(remotePath, localPath, t.opts.Compression)
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.opts.Verify {
+		localHash, err := FileSHA256(localPath)
+		if err != nil {
+			return err
+		}
+		if localHash != remoteHash {
+			return fmt.Errorf("checksum mismatch for %s", localPath)
+		}
+	}
+	return nil
+}
+
+func (t *transferer) UploadDir(localDir, remotePath string, progress ProgressFunc) error {
+	return t.backend.sendDir(localDir, remotePath, progress)
+}
+
+func (t *transferer) DownloadDir(remotePath, localDir string, progress ProgressFunc) error {
+	return t.backend.receiveDir(remotePath, localDir, progress)
+}
+
+func (t *transferer) Close() error {
+	return t.backend.close()
+}
+
+// scpBackend relies on the scp binary of the box
+type scpBackend struct {
+	conn *Connection
+}
+
+func (b *scpBackend) sendFile(localPath, remotePath string) error {
+	return scp.NewSCP(b.conn.Client).SendFile(localPath, remotePath)
+}
+
+func (b *scpBackend) receiveFile(remotePath, localPath string) error {
+	return scp.NewSCP(b.conn.Client).ReceiveFile(remotePath, localPath)
+}
+
+// sendDir preserves modes and times, like scp -r
+func (b *scpBackend) sendDir(localDir, remotePath string, progress ProgressFunc) error {
+	return scp.NewSCP(b.conn.Client).SendDir(localDir, remotePath, progressAccept(progress))
+}
+
+// receiveDir preserves modes and times, like scp -r
+func (b *scpBackend) receiveDir(remotePath, localDir string, progress ProgressFunc) error {
+	return scp.NewSCP(b.conn.Client).ReceiveDir(remotePath, localDir, progressAccept(progress))
+}
+
+func (b *scpBackend) close() error {
+	return nil
+}
+
+func (conn *Connection) uploadCompressed(localPath, remotePath, compression string) error {
+	compression, err := conn.remoteCompression(compression)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := remotePath + ".fleex-part"
+	cmd := fmt.Sprintf("%s -dc > %s && chmod %o %s && mv -f %s %s",
+		compression, shellQuote(tmpPath), info.Mode().Perm(), shellQuote(tmpPath), shellQuote(tmpPath), shellQuote(remotePath))
+
+	copyErr := make(chan error, 1)
+	go func() {
+		defer stdin.Close()
+		w, err := newCompressor(stdin, compression)
+		if err != nil {
+			copyErr <- err
+			return
+		}
+		if _, err := io.Copy(w, file); err != nil {
+			copyErr <- err
+			return
+		}
+		copyErr <- w.Close()
+	}()
+
+	output, runErr := session.CombinedOutput(cmd)
+	if err := <-copyErr; err != nil {
+		return err
+	}
+	if runErr != nil {
+		return fmt.Errorf("upload of %s failed: %v %s", localPath, runErr, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (conn *Connection) downloadCompressed(remotePath, localPath, compression string) error {
+	compression, err := conn.remoteCompression(compression)
+	if err != nil {
+		return err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := session.Start(fmt.Sprintf("%s -c %s", compression, shellQuote(remotePath))); err != nil {
+		return err
+	}
+
+	tmpPath := localPath + ".fleex-part"
+	if err := writeDecompressed(stdout, tmpPath, compression); err != nil {
+		session.Wait()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := session.Wait(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("download of %s failed: %w", remotePath, err)
+	}
+	return os.Rename(tmpPath, localPath)
+}
+
+// remoteCompression falls back to gzip when zstd is not installed on the box
+func (conn *Connection) remoteCompression(compression string) (string, error) {
+	switch compression {
+	case models.CompressionGzip:
+		return compression, nil
+	case models.CompressionZstd:
+		session, err := conn.NewSession()
+		if err != nil {
+			return "", err
+		}
+		defer session.Close()
+		if err := session.Run("command -v zstd >/dev/null"); err != nil {
+			return models.CompressionGzip, nil
+		}
+		return compression, nil
+	default:
+		return "", fmt.Errorf("unknown compression: %s", compression)
+	}
+}
+
+func newCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	if compression == models.CompressionZstd {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	}
+	return gzip.NewWriterLevel(w, gzip.BestSpeed)
+}
+
+func writeDecompressed(r io.Reader, path, compression string) error {
+	var reader io.Reader
+	if compression == models.CompressionZstd {
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer decoder.Close()
+		reader = decoder
+	} else {
+		decoder, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer decoder.Close()
+		reader = decoder
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (conn *Connection) remoteSHA256(path string) (string, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	output, err := session.Output("sha256sum " + shellQuote(path))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no checksum for %s", path)
+	}
+	return fields[0], nil
+}
+
+// FileSHA256 returns the hex encoded SHA-256 of a local file
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ProgressFunc is called for each file as it is transferred
+type ProgressFunc func(name string, size int64)
+
+func progressAccept(progress ProgressFunc) scp.AcceptFunc {
+	return func(parentDir string, info os.FileInfo) (bool, error) {
+		if progress != nil && !info.IsDir() {
+			progress(filepath.Join(parentDir, info.Name()), info.Size())
+		}
+		return true, nil
+	}
+}
+
+// IsRemoteDir reports whether path is a directory on the box
+func (conn *Connection) IsRemoteDir(path string) bool {
+	session, err := conn.NewSession()
+	if err != nil {
+		return false
+	}
+	defer session.Close()
+	return session.Run("test -d "+shellQuote(path)) == nil
+}
+
+// RemoteGlob expands pattern with the remote shell and returns the existing
+// paths it matches
+func (conn *Connection) RemoteGlob(pattern string) ([]string, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	output, err := session.Output(fmt.Sprintf(`for f in %s; do [ -e "$f" ] && printf '%%s\n' "$f"; done; true`, pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
+
+// MkdirAll creates path and its parents on the box
+func (conn *Connection) MkdirAll(path string) error {
+	session, err := conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if output, err := session.CombinedOutput("mkdir -p " + shellQuote(path)); err != nil {
+		return fmt.Errorf("mkdir %s failed: %v %s", path, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -2,12 +2,15 @@ package ui
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pterm/pterm"
 )
 
 type SpawnProgress struct {
+	mu        sync.Mutex
 	spinner   *pterm.SpinnerPrinter
 	multi     *pterm.MultiPrinter
 	bars      map[string]*pterm.ProgressbarPrinter
@@ -16,6 +19,14 @@ type SpawnProgress struct {
 	ready     int
 }
 
+// Readiness phases of a new box
+const (
+	PhaseProvisioning = "provisioning"
+	PhaseSSH          = "ssh"
+	PhaseCloudInit    = "cloud-init"
+	PhaseReady        = "ready"
+)
+
 func NewSpawnProgress(total int) *SpawnProgress {
 	return &SpawnProgress{
 		boxStates: make(map[string]string),
@@ -56,24 +67,41 @@ func (sp *SpawnProgress) StartWaiting() {
 		Start("Waiting for instances to become ready...")
 }
 
-func (sp *SpawnProgress) UpdateBoxStatus(name, status, ip string) {
-	sp.boxStates[name] = status
-	ready := 0
+// UpdateBoxPhase records the readiness phase of a box
+func (sp *SpawnProgress) UpdateBoxPhase(name, phase string) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	sp.boxStates[name] = phase
+	counts := make(map[string]int)
 	for _, s := range sp.boxStates {
-		if s == "running" || s == "active" {
-			ready++
-		}
+		counts[s]++
 	}
-	sp.ready = ready
+	sp.ready = counts[PhaseReady]
 
 	if sp.spinner != nil {
-		sp.spinner.UpdateText(fmt.Sprintf("Waiting for instances... %d/%d ready", ready, sp.total))
+		sp.spinner.UpdateText(fmt.Sprintf("Waiting for instances... %d/%d ready (provisioning: %d, ssh: %d, cloud-init: %d)",
+			sp.ready, sp.total, counts[PhaseProvisioning], counts[PhaseSSH], counts[PhaseCloudInit]))
 	}
 }
 
+// BoxReplaced reports a box deleted because it didn't become ready in time
+func (sp *SpawnProgress) BoxReplaced(name, reason string) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	delete(sp.boxStates, name)
+	pterm.Warning.Printfln("%s not ready, replacing it: %s", name, reason)
+}
+
 func (sp *SpawnProgress) WaitingDone() {
-	if sp.spinner != nil {
+	if sp.spinner == nil {
+		return
+	}
+	if sp.ready >= sp.total {
 		sp.spinner.Success(fmt.Sprintf("All %d instances ready", sp.total))
+	} else {
+		sp.spinner.Warning(fmt.Sprintf("%d/%d instances ready", sp.ready, sp.total))
 	}
 }
 
@@ -82,6 +110,17 @@ func (sp *SpawnProgress) Done() {
 	fmt.Println()
 }
 
+// ReplacedBoxes reports the boxes replaced by new boxes with the same label
+// and the ones given up after too many replacements
+func ReplacedBoxes(replaced, failed []string) {
+	if len(replaced) > 0 {
+		pterm.Warning.Printfln("Replaced boxes: %s", strings.Join(replaced, ", "))
+	}
+	if len(failed) > 0 {
+		pterm.Error.Printfln("Gave up on boxes: %s", strings.Join(failed, ", "))
+	}
+}
+
 type BuildProgress struct {
 	fleetSize int
 	boxes     map[string]*boxProgress
@@ -169,6 +208,90 @@ func (bp *BuildProgress) Done() {
 	}
 }
 
+func ShowRunSummary(results []RunResult) {
+	fmt.Println()
+	pterm.DefaultSection.Println("Run Summary")
+
+	tableData := pterm.TableData{
+		{"Box", "Status", "Exit Code", "Duration"},
+	}
+
+	success := 0
+	for _, r := range results {
+		status := pterm.Green("Success")
+		exitCode := fmt.Sprintf("%d", r.ExitCode)
+		if r.Error != "" {
+			status = pterm.Red("Error: " + r.Error)
+			exitCode = "-"
+		} else if r.ExitCode != 0 {
+			status = pterm.Red("Failed")
+		} else {
+			success++
+		}
+
+		tableData = append(tableData, []string{
+			r.BoxName,
+			status,
+			exitCode,
+			r.Duration.Round(time.Millisecond).String(),
+		})
+	}
+
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+	fmt.Println()
+	if success == len(results) {
+		pterm.Success.Printfln("Command succeeded on %d/%d boxes", success, len(results))
+	} else {
+		pterm.Warning.Printfln("Command succeeded on %d/%d boxes", success, len(results))
+	}
+}
+
+type RunResult struct {
+	BoxName  string
+	ExitCode int
+	Duration time.Duration
+	Error    string
+}
+
+// ShowFleetPlan prints the changes needed to reconcile a fleet with its manifest
+func ShowFleetPlan(fleetName string, current, desired int, actions []PlanAction) {
+	fmt.Println()
+	pterm.DefaultSection.Printfln("Plan for %s (%d/%d boxes)", fleetName, current, desired)
+
+	if len(actions) == 0 {
+		pterm.Success.Println("Fleet matches its manifest, nothing to do")
+		return
+	}
+
+	tableData := pterm.TableData{
+		{"Action", "Box", "Region", "Reason"},
+	}
+	for _, a := range actions {
+		action := a.Type
+		switch a.Type {
+		case "spawn":
+			action = pterm.Green(fmt.Sprintf("+ spawn %d", a.Count))
+		case "delete":
+			action = pterm.Red("- delete")
+		case "replace":
+			action = pterm.Yellow("~ replace")
+		case "rebuild":
+			action = pterm.Cyan("~ rebuild")
+		}
+		tableData = append(tableData, []string{action, a.Box, a.Region, a.Reason})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+}
+
+type PlanAction struct {
+	Type   string
+	Box    string
+	Region string
+	Count  int
+	Reason string
+}
+
 func PrintFleetTable(boxes []FleetBox) {
 	tableData := pterm.TableData{
 		{"Name", "Status", "IP", "Duration"},
@@ -238,6 +361,20 @@ type BuildResult struct {
 	Error          string
 }
 
+// Finding prints a line streamed from a box while a scan is running
+func Finding(box, line string) {
+	pterm.Printfln("%s %s", pterm.FgCyan.Sprintf("[%s]", box), line)
+}
+
+// BoxOutput prints a line of command output prefixed with the box label
+func BoxOutput(box, line string, stderr bool) {
+	if stderr {
+		pterm.Printfln("%s %s", pterm.FgRed.Sprintf("[%s]", box), line)
+		return
+	}
+	pterm.Printfln("%s %s", pterm.FgCyan.Sprintf("[%s]", box), line)
+}
+
 func Info(msg string) {
 	pterm.Info.Println(msg)
 }
@@ -262,6 +399,7 @@ type WorkflowProgress struct {
 	fleetSize int
 	boxes     map[string]*workflowBoxProgress
 	spinner   *pterm.SpinnerPrinter
+	quiet     bool
 }
 
 type workflowBoxProgress struct {
@@ -281,7 +419,18 @@ func NewWorkflowProgress(fleetSize int) *WorkflowProgress {
 	}
 }
 
+// NewQuietWorkflowProgress returns a progress display that prints nothing,
+// for workflows running side by side in the same terminal
+func NewQuietWorkflowProgress(fleetSize int) *WorkflowProgress {
+	wp := NewWorkflowProgress(fleetSize)
+	wp.quiet = true
+	return wp
+}
+
 func (wp *WorkflowProgress) Start(workflowName string, totalSteps int) {
+	if wp.quiet {
+		return
+	}
 	pterm.DefaultHeader.WithBackgroundStyle(pterm.NewStyle(pterm.BgMagenta)).
 		WithTextStyle(pterm.NewStyle(pterm.FgWhite)).
 		Printf("Running workflow: %s (%d steps)", workflowName, totalSteps)
@@ -289,42 +438,63 @@ func (wp *WorkflowProgress) Start(workflowName string, totalSteps int) {
 }
 
 func (wp *WorkflowProgress) StartSetup() {
+	if wp.quiet {
+		return
+	}
 	wp.spinner, _ = pterm.DefaultSpinner.
 		WithRemoveWhenDone(true).
 		Start("Running setup commands...")
 }
 
 func (wp *WorkflowProgress) SetupDone() {
+	if wp.quiet {
+		return
+	}
 	if wp.spinner != nil {
 		wp.spinner.Success("Setup complete")
 	}
 }
 
 func (wp *WorkflowProgress) StartFileTransfer() {
+	if wp.quiet {
+		return
+	}
 	wp.spinner, _ = pterm.DefaultSpinner.
 		WithRemoveWhenDone(true).
 		Start("Transferring files to fleet...")
 }
 
 func (wp *WorkflowProgress) FileTransferDone(count int) {
+	if wp.quiet {
+		return
+	}
 	if wp.spinner != nil {
 		wp.spinner.Success(fmt.Sprintf("Transferred %d file(s) to all boxes", count))
 	}
 }
 
 func (wp *WorkflowProgress) StartChunking(inputFile string) {
+	if wp.quiet {
+		return
+	}
 	wp.spinner, _ = pterm.DefaultSpinner.
 		WithRemoveWhenDone(true).
 		Start(fmt.Sprintf("Splitting input file: %s", inputFile))
 }
 
 func (wp *WorkflowProgress) ChunkingDone(chunks int) {
+	if wp.quiet {
+		return
+	}
 	if wp.spinner != nil {
 		wp.spinner.Success(fmt.Sprintf("Input split into %d chunks", chunks))
 	}
 }
 
 func (wp *WorkflowProgress) StartBox(name string, totalSteps int) {
+	if wp.quiet {
+		return
+	}
 	spinner, _ := pterm.DefaultSpinner.
 		WithRemoveWhenDone(false).
 		Start(fmt.Sprintf("[%s] Starting workflow...", name))
@@ -337,6 +507,9 @@ func (wp *WorkflowProgress) StartBox(name string, totalSteps int) {
 }
 
 func (wp *WorkflowProgress) UpdateStep(boxName, stepName string, stepNum int) {
+	if wp.quiet {
+		return
+	}
 	if box, ok := wp.boxes[boxName]; ok {
 		box.step = stepName
 		box.stepNum = stepNum
@@ -347,6 +520,9 @@ func (wp *WorkflowProgress) UpdateStep(boxName, stepName string, stepNum int) {
 }
 
 func (wp *WorkflowProgress) BoxSuccess(boxName string) {
+	if wp.quiet {
+		return
+	}
 	if box, ok := wp.boxes[boxName]; ok {
 		box.finished = true
 		box.success = true
@@ -357,6 +533,9 @@ func (wp *WorkflowProgress) BoxSuccess(boxName string) {
 }
 
 func (wp *WorkflowProgress) BoxFailed(boxName string, err string) {
+	if wp.quiet {
+		return
+	}
 	if box, ok := wp.boxes[boxName]; ok {
 		box.finished = true
 		box.success = false
@@ -367,18 +546,27 @@ func (wp *WorkflowProgress) BoxFailed(boxName string, err string) {
 }
 
 func (wp *WorkflowProgress) StartAggregating() {
+	if wp.quiet {
+		return
+	}
 	wp.spinner, _ = pterm.DefaultSpinner.
 		WithRemoveWhenDone(true).
 		Start("Aggregating results...")
 }
 
 func (wp *WorkflowProgress) AggregatingDone(outputFile string) {
+	if wp.quiet {
+		return
+	}
 	if wp.spinner != nil {
 		wp.spinner.Success(fmt.Sprintf("Results saved to: %s", outputFile))
 	}
 }
 
 func (wp *WorkflowProgress) Done() {
+	if wp.quiet {
+		return
+	}
 	success := 0
 	for _, box := range wp.boxes {
 		if box.success {
@@ -393,3 +581,62 @@ func (wp *WorkflowProgress) Done() {
 		pterm.Warning.Printfln("Workflow complete: %d/%d successful", success, wp.fleetSize)
 	}
 }
+
+type TransferProgress struct {
+	mu      sync.Mutex
+	spinner *pterm.SpinnerPrinter
+	files   int
+	bytes   int64
+	failed  int
+}
+
+func NewTransferProgress() *TransferProgress {
+	return &TransferProgress{}
+}
+
+func (tp *TransferProgress) Start(title string) {
+	tp.spinner, _ = pterm.DefaultSpinner.
+		WithRemoveWhenDone(true).
+		Start(title)
+}
+
+func (tp *TransferProgress) File(boxName, name string, size int64) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.files++
+	tp.bytes += size
+	if tp.spinner != nil {
+		tp.spinner.UpdateText(fmt.Sprintf("[%s] %s (%d files, %s)", boxName, name, tp.files, formatBytes(tp.bytes)))
+	}
+}
+
+func (tp *TransferProgress) BoxFailed(boxName string, err string) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.failed++
+	pterm.Error.Printfln("[%s] Transfer failed: %s", boxName, err)
+}
+
+func (tp *TransferProgress) Done(boxes int) {
+	if tp.spinner != nil {
+		tp.spinner.Stop()
+	}
+	if tp.failed == 0 {
+		pterm.Success.Printfln("Transferred %d files (%s) on %d boxes", tp.files, formatBytes(tp.bytes), boxes)
+	} else {
+		pterm.Warning.Printfln("Transferred %d files (%s), %d/%d boxes failed", tp.files, formatBytes(tp.bytes), tp.failed, boxes)
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
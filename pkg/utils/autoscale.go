@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseAutoscale parses a min:max fleet size range
+func ParseAutoscale(value string) (int, int, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid autoscale range %q, expected min:max", value)
+	}
+
+	min, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || min < 0 {
+		return 0, 0, fmt.Errorf("invalid autoscale minimum %q", parts[0])
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || max < 1 || max < min {
+		return 0, 0, fmt.Errorf("invalid autoscale maximum %q, must be at least 1 and the minimum", parts[1])
+	}
+	return min, max, nil
+}
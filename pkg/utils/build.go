@@ -87,12 +87,17 @@ func SaveBuildRecipe(recipe *models.BuildRecipe) error {
 	return os.WriteFile(path, data, 0644)
 }
 
-func ReplaceBuildVars(text string, vars map[string]string) string {
+// ReplaceBuildVars expands {vars.X} placeholders. Any placeholder left
+// unresolved is returned as an error.
+func ReplaceBuildVars(text string, vars map[string]string) (string, error) {
 	for k, v := range vars {
 		placeholder := fmt.Sprintf("{vars.%s}", k)
 		text = strings.ReplaceAll(text, placeholder, v)
 	}
-	return text
+	if err := checkUnresolvedVars(text); err != nil {
+		return "", err
+	}
+	return text, nil
 }
 
 func ExpandPath(path string) string {
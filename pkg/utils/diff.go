@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+)
+
+// DiffFiles compares two outputs line by line. If key is set, lines are
+// parsed as JSON and compared on that field (dot notation for nested fields).
+// A missing old file is treated as empty.
+func DiffFiles(oldPath, newPath, key string) (models.DiffResult, error) {
+	result := models.DiffResult{}
+
+	oldLines, err := readKeyedLines(oldPath, key)
+	if err != nil && !os.IsNotExist(err) {
+		return result, err
+	}
+	newLines, err := readKeyedLines(newPath, key)
+	if err != nil && !os.IsNotExist(err) {
+		return result, err
+	}
+
+	oldKeys := make(map[string]bool, len(oldLines))
+	for _, l := range oldLines {
+		oldKeys[l.key] = true
+	}
+	newKeys := make(map[string]bool, len(newLines))
+	for _, l := range newLines {
+		if newKeys[l.key] {
+			continue
+		}
+		newKeys[l.key] = true
+		if oldKeys[l.key] {
+			result.Unchanged = append(result.Unchanged, l.text)
+		} else {
+			result.New = append(result.New, l.text)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, l := range oldLines {
+		if !newKeys[l.key] && !seen[l.key] {
+			seen[l.key] = true
+			result.Removed = append(result.Removed, l.text)
+		}
+	}
+	return result, nil
+}
+
+// WriteDiff writes the sets of a diff next to output, e.g. results-new.txt,
+// results-removed.txt and results-unchanged.txt
+func WriteDiff(diff models.DiffResult, output string) error {
+	sets := map[string][]string{
+		"new":       diff.New,
+		"removed":   diff.Removed,
+		"unchanged": diff.Unchanged,
+	}
+	for name, lines := range sets {
+		content := strings.Join(lines, "\n")
+		if len(lines) > 0 {
+			content += "\n"
+		}
+		if err := os.WriteFile(DiffOutputPath(output, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func DiffOutputPath(output, set string) string {
+	ext := filepath.Ext(output)
+	return strings.TrimSuffix(output, ext) + "-" + set + ext
+}
+
+type keyedLine struct {
+	key  string
+	text string
+}
+
+func readKeyedLines(path, key string) ([]keyedLine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []keyedLine
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		lineKey := text
+		if key != "" {
+			lineKey, err = jsonField(text, key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		lines = append(lines, keyedLine{key: lineKey, text: text})
+	}
+	return lines, scanner.Err()
+}
+
+func jsonField(line, key string) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(line), &value); err != nil {
+		return "", fmt.Errorf("invalid JSON line: %w", err)
+	}
+
+	for _, part := range strings.Split(key, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("field %s not found", key)
+		}
+		value, ok = obj[part]
+		if !ok {
+			return "", fmt.Errorf("field %s not found", key)
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	return string(data), err
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FleetTagPrefix marks the fleet a box was spawned in, as fleex:fleet=<name>
+	FleetTagPrefix = "fleex:fleet="
+	// fleetTagPrefixStrict is used by providers that don't allow '=' in tags
+	fleetTagPrefixStrict = "fleex:fleet:"
+)
+
+var (
+	invalidStrictTagChars = regexp.MustCompile(`[^a-zA-Z0-9_:\-]`)
+	fleetNameRegex        = regexp.MustCompile(`^[a-zA-Z0-9_:\-]+$`)
+)
+
+// ValidateFleetName rejects names that can't be stored as is in a strict fleet
+// tag, two such fleets could end up with the same tag
+func ValidateFleetName(name string) error {
+	if !fleetNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid fleet name %q: only letters, digits, _, - and : are allowed", name)
+	}
+	return nil
+}
+
+// FleetTag returns the tag attached to the boxes of a fleet
+func FleetTag(name string) string {
+	return FleetTagPrefix + name
+}
+
+// FleetTagStrict returns the fleet tag for providers restricting tags to
+// letters, digits, '_', '-' and ':'
+func FleetTagStrict(name string) string {
+	return fleetTagPrefixStrict + invalidStrictTagChars.ReplaceAllString(name, "_")
+}
+
+// FleetFromTags returns the fleet name found in the tags of a box, or an
+// empty string for boxes that were not spawned with a fleet tag
+func FleetFromTags(tags []string) string {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, FleetTagPrefix) {
+			return strings.TrimPrefix(tag, FleetTagPrefix)
+		}
+		if strings.HasPrefix(tag, fleetTagPrefixStrict) {
+			return strings.TrimPrefix(tag, fleetTagPrefixStrict)
+		}
+	}
+	return ""
+}
+
+// BoxInFleet reports whether a box belongs to the fleet name: its label is
+// the name itself, or it carries the fleet tag, FleetTagStrict when strict is
+// set. Label prefix matching is only used when legacy is set.
+func BoxInFleet(label string, tags []string, name string, strict, legacy bool) bool {
+	if label == name {
+		return true
+	}
+	fleetTag := FleetTag(name)
+	if strict {
+		fleetTag = FleetTagStrict(name)
+	}
+	for _, tag := range tags {
+		if tag == fleetTag {
+			return true
+		}
+	}
+	return legacy && MatchesFleetName(label, name)
+}
+
+// NewBoxNames returns count unused labels for new boxes of a fleet, numbered
+// after the highest <name>-<n> label among boxes
+func NewBoxNames(name string, count int, labels []string) []string {
+	highest := 0
+	for _, label := range labels {
+		suffix := strings.TrimPrefix(label, name+"-")
+		if suffix == label {
+			continue
+		}
+		if n, err := strconv.Atoi(suffix); err == nil && n > highest {
+			highest = n
+		}
+	}
+
+	names := make([]string, count)
+	for i := range names {
+		names[i] = name + "-" + strconv.Itoa(highest+i+1)
+	}
+	return names
+}
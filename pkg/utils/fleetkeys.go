@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+)
+
+func GetFleetKeysDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "fleex", "keys"), nil
+}
+
+// FleetKeyPaths returns where the keypair of a fleet is stored. Names are
+// sanitized like strict fleet tags, so a fleet found by tag maps to the same
+// folder.
+func FleetKeyPaths(fleetName string) (models.SSHKeys, error) {
+	keysDir, err := GetFleetKeysDir()
+	if err != nil {
+		return models.SSHKeys{}, err
+	}
+	dir := filepath.Join(keysDir, invalidStrictTagChars.ReplaceAllString(fleetName, "_"))
+	return models.SSHKeys{
+		PrivateFile: filepath.Join(dir, "id_ed25519"),
+		PublicFile:  filepath.Join(dir, "id_ed25519.pub"),
+	}, nil
+}
+
+// fleetKeysOwnerFile records the name of the fleet a keys folder belongs to,
+// as names sanitized the same way share a folder
+const fleetKeysOwnerFile = "fleet"
+
+// ClaimFleetKeys prepares the keys folder of a new fleet and returns where
+// its keypair goes. It fails if the folder belongs to another fleet whose
+// name is sanitized the same way.
+func ClaimFleetKeys(fleetName string) (models.SSHKeys, error) {
+	keys, err := FleetKeyPaths(fleetName)
+	if err != nil {
+		return keys, err
+	}
+	if owner := fleetKeysOwner(fleetName); owner != "" && owner != fleetName {
+		return keys, fmt.Errorf("fleet %s would share the SSH keys of fleet %s, use another name", fleetName, owner)
+	}
+
+	dir := filepath.Dir(keys.PrivateFile)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return keys, err
+	}
+	return keys, os.WriteFile(filepath.Join(dir, fleetKeysOwnerFile), []byte(fleetName+"\n"), 0600)
+}
+
+// fleetKeysOwner returns the fleet the keys folder of fleetName was created
+// for, empty if unknown
+func fleetKeysOwner(fleetName string) string {
+	keys, err := FleetKeyPaths(fleetName)
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(keys.PrivateFile), fleetKeysOwnerFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// HasFleetKeys reports whether the fleet has its own keypair
+func HasFleetKeys(fleetName string) bool {
+	keys, err := FleetKeyPaths(fleetName)
+	return err == nil && FileExists(keys.PrivateFile)
+}
+
+// OwnsFleetKeys reports whether the fleet has its own keypair, and not one of
+// a fleet whose name is sanitized the same way
+func OwnsFleetKeys(fleetName string) bool {
+	if !HasFleetKeys(fleetName) {
+		return false
+	}
+	owner := fleetKeysOwner(fleetName)
+	return owner == "" || owner == fleetName
+}
+
+// FleetSSHKeys returns the keypair of a fleet, or fallback for fleets spawned
+// without their own keypair
+func FleetSSHKeys(fleetName string, fallback models.SSHKeys) models.SSHKeys {
+	if fleetName == "" || !OwnsFleetKeys(fleetName) {
+		return fallback
+	}
+	keys, _ := FleetKeyPaths(fleetName)
+	return keys
+}
+
+// BoxSSHKeys returns the keypair of the fleet a box was spawned in, found by
+// its fleet tag. Strict tags only hold the sanitized name, so the owner of
+// the keys folder is not checked.
+func BoxSSHKeys(tags []string, fallback models.SSHKeys) models.SSHKeys {
+	fleetName := FleetFromTags(tags)
+	if fleetName == "" || !HasFleetKeys(fleetName) {
+		return fallback
+	}
+	keys, _ := FleetKeyPaths(fleetName)
+	return keys
+}
+
+// DeleteFleetKeys removes the keypair of a fleet, unless it belongs to
+// another fleet
+func DeleteFleetKeys(fleetName string) error {
+	keys, err := FleetKeyPaths(fleetName)
+	if err != nil {
+		return err
+	}
+	if owner := fleetKeysOwner(fleetName); owner != "" && owner != fleetName {
+		return nil
+	}
+	return os.RemoveAll(filepath.Dir(keys.PrivateFile))
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/term"
+)
+
+// KeystorePassphraseEnv unlocks the keystore without prompting, e.g. in the
+// daemon
+const KeystorePassphraseEnv = "FLEEX_KEYSTORE_PASSPHRASE"
+
+// ErrWrongPassphrase is returned when the keystore can't be decrypted
+var ErrWrongPassphrase = errors.New("wrong keystore passphrase")
+
+// Keystore holds named secrets encrypted with a key derived from a passphrase
+type Keystore struct {
+	path    string
+	salt    []byte
+	key     []byte
+	secrets map[string]string
+}
+
+type keystoreFile struct {
+	Salt []byte `json:"salt"`
+	// Time is the argon2id time cost, 1 in keystores that don't record it
+	Time  uint32 `json:"time,omitempty"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func GetKeystorePath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "fleex", "keystore.json"), nil
+}
+
+func KeystoreExists() bool {
+	path, err := GetKeystorePath()
+	return err == nil && FileExists(path)
+}
+
+// keystoreTime is the argon2id time cost of the RFC 9106 profile using 64 MiB
+const keystoreTime = 3
+
+func keystoreKey(passphrase string, salt []byte, time uint32) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, time, 64*1024, 4, 32)
+}
+
+// OpenKeystore decrypts the keystore, or returns an empty one if it doesn't
+// exist yet
+func OpenKeystore(passphrase string) (*Keystore, error) {
+	path, err := GetKeystorePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		return &Keystore{path: path, salt: salt, key: keystoreKey(passphrase, salt, keystoreTime), secrets: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid keystore %s: %v", path, err)
+	}
+
+	if file.Time == 0 {
+		file.Time = 1
+	}
+	ks := &Keystore{path: path, salt: file.Salt, key: keystoreKey(passphrase, file.Salt, file.Time)}
+	gcm, err := ks.cipher()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plain, &ks.secrets); err != nil {
+		return nil, err
+	}
+	if ks.secrets == nil {
+		ks.secrets = make(map[string]string)
+	}
+	if file.Time < keystoreTime {
+		// Older keystores are saved with the current cost from now on
+		ks.key = keystoreKey(passphrase, ks.salt, keystoreTime)
+	}
+	return ks, nil
+}
+
+func (k *Keystore) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *Keystore) Get(name string) (string, bool) {
+	value, ok := k.secrets[name]
+	return value, ok
+}
+
+func (k *Keystore) Set(name, value string) {
+	k.secrets[name] = value
+}
+
+// Delete removes a secret and reports whether it existed
+func (k *Keystore) Delete(name string) bool {
+	_, ok := k.secrets[name]
+	delete(k.secrets, name)
+	return ok
+}
+
+// Names returns the names of the secrets, sorted
+func (k *Keystore) Names() []string {
+	names := make([]string, 0, len(k.secrets))
+	for name := range k.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the secrets with a new nonce and writes the keystore
+func (k *Keystore) Save() error {
+	plain, err := json.Marshal(k.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := k.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(keystoreFile{
+		Salt:  k.salt,
+		Time:  keystoreTime,
+		Nonce: nonce,
+		Data:  gcm.Seal(nil, nonce, plain, nil),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(k.path, data, 0600)
+}
+
+// KeystorePassphrase reads the passphrase from FLEEX_KEYSTORE_PASSPHRASE or
+// from the terminal. With confirm set, it is asked twice for a new keystore.
+func KeystorePassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(KeystorePassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("keystore is locked, set %s", KeystorePassphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "Keystore passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("empty keystore passphrase")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(passphrase) {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
+
+// UnlockKeystore asks for the passphrase and opens the keystore, creating it
+// if needed
+func UnlockKeystore() (*Keystore, error) {
+	passphrase, err := KeystorePassphrase(!KeystoreExists())
+	if err != nil {
+		return nil, err
+	}
+	return OpenKeystore(passphrase)
+}
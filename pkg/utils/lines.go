@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+)
+
+const lineBufferSize = 64 * 1024
+
+// ReadLines calls fn for every line of r with bounded memory, whatever the
+// line length. key is the start of the line (at most 64KB, without the line
+// ending), only valid until write is called. write copies the whole line to
+// w, ending with "\n" even if it was "\r\n" or the last line had no line
+// ending. Lines fn doesn't write are skipped.
+func ReadLines(r io.Reader, fn func(key []byte, write func(w io.Writer) error) error) error {
+	reader := bufio.NewReaderSize(r, lineBufferSize)
+
+	for {
+		fragment, err := reader.ReadSlice('\n')
+		if len(fragment) == 0 && err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return err
+		}
+
+		complete := err != bufio.ErrBufferFull
+		key := bytes.TrimRight(fragment, "\r\n")
+		written := false
+
+		write := func(w io.Writer) error {
+			written = true
+			if complete {
+				return writeLine(w, key)
+			}
+			return copyLongLine(reader, fragment, w)
+		}
+
+		if cbErr := fn(key, write); cbErr != nil {
+			return cbErr
+		}
+		if !written && !complete {
+			if err := copyLongLine(reader, fragment, io.Discard); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeLine(w io.Writer, line []byte) error {
+	if _, err := w.Write(line); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
+
+// copyLongLine writes a line that didn't fit in the reader buffer, starting
+// with the fragment already read
+func copyLongLine(reader *bufio.Reader, fragment []byte, w io.Writer) error {
+	// A trailing \r is held back until we know whether \n follows
+	pendingCR := false
+	for {
+		if pendingCR {
+			if len(fragment) > 0 && fragment[0] != '\n' {
+				if _, err := w.Write([]byte{'\r'}); err != nil {
+					return err
+				}
+			}
+			pendingCR = false
+		}
+
+		data := fragment
+		last := len(data) > 0 && data[len(data)-1] == '\n'
+		if last {
+			data = bytes.TrimRight(data, "\r\n")
+		} else if len(data) > 0 && data[len(data)-1] == '\r' {
+			data = data[:len(data)-1]
+			pendingCR = true
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if last {
+			_, err := w.Write([]byte{'\n'})
+			return err
+		}
+
+		var err error
+		fragment, err = reader.ReadSlice('\n')
+		if err == io.EOF {
+			if pendingCR && len(fragment) > 0 {
+				if _, err := w.Write([]byte{'\r'}); err != nil {
+					return err
+				}
+			}
+			if len(fragment) > 0 {
+				if _, err := w.Write(bytes.TrimRight(fragment, "\r")); err != nil {
+					return err
+				}
+			}
+			_, err := w.Write([]byte{'\n'})
+			return err
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return err
+		}
+	}
+}
+
+// CountFileLines returns the number of lines of a file, counting a last line
+// without line ending
+func CountFileLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	err = ReadLines(file, func([]byte, func(io.Writer) error) error {
+		count++
+		return nil
+	})
+	return count, err
+}
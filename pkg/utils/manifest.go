@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"gopkg.in/yaml.v2"
+)
+
+// ReadFleetManifest loads and validates a fleet.yaml file
+func ReadFleetManifest(path string) (*models.FleetManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &models.FleetManifest{}
+	if err := yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("manifest %s: name is required", path)
+	}
+	if err := ValidateFleetName(manifest.Name); err != nil {
+		return nil, fmt.Errorf("manifest %s: %v", path, err)
+	}
+	if manifest.Provider == "" {
+		return nil, fmt.Errorf("manifest %s: provider is required", path)
+	}
+	if manifest.Count < 0 {
+		return nil, fmt.Errorf("manifest %s: count must not be negative", path)
+	}
+	if manifest.TTL != "" {
+		if _, err := time.ParseDuration(manifest.TTL); err != nil {
+			return nil, fmt.Errorf("manifest %s: invalid ttl: %v", path, err)
+		}
+	}
+	return manifest, nil
+}
+
+func GetManifestsDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "fleex", "manifests"), nil
+}
+
+// manifestStatePath returns the state file of a manifest
+func manifestStatePath(name string) (string, error) {
+	if err := ValidateFleetName(name); err != nil {
+		return "", err
+	}
+	manifestsDir, err := GetManifestsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(manifestsDir, name+".json"), nil
+}
+
+// ReadManifestState returns the state of an applied manifest, nil if the
+// manifest was never applied
+func ReadManifestState(name string) (*models.ManifestState, error) {
+	path, err := manifestStatePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &models.ManifestState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func SaveManifestState(state *models.ManifestState) error {
+	path, err := manifestStatePath(state.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func DeleteManifestState(name string) error {
+	path, err := manifestStatePath(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ListManifestStates returns the state of every applied manifest
+func ListManifestStates() ([]models.ManifestState, error) {
+	manifestsDir, err := GetManifestsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(manifestsDir)
+	if os.IsNotExist(err) {
+		return []models.ManifestState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var states []models.ManifestState
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		state, err := ReadManifestState(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil || state == nil {
+			continue
+		}
+		states = append(states, *state)
+	}
+	return states, nil
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+)
+
+// ExpandMatrix returns every combination of the matrix values. Keys are
+// iterated in alphabetical order so the result is deterministic.
+func ExpandMatrix(matrix map[string][]string) []map[string]string {
+	keys := make([]string, 0, len(matrix))
+	for k := range matrix {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{{}}
+	for _, key := range keys {
+		var expanded []map[string]string
+		for _, combination := range combinations {
+			for _, value := range matrix[key] {
+				next := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					next[k] = v
+				}
+				next[key] = value
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+
+	if len(combinations) == 1 && len(combinations[0]) == 0 {
+		return nil
+	}
+	return combinations
+}
+
+// MatrixSuffix builds a file name friendly suffix for a matrix combination,
+// e.g. SEVERITY-high_TEMPLATES-cves
+func MatrixSuffix(combination map[string]string) string {
+	keys := make([]string, 0, len(combination))
+	for k := range combination {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, sanitizeSuffix(k)+"-"+sanitizeSuffix(combination[k]))
+	}
+	return strings.Join(parts, "_")
+}
+
+func sanitizeSuffix(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' {
+			return r
+		}
+		return '-'
+	}, s)
+	return strings.Trim(s, "-.")
+}
@@ -1,14 +1,35 @@
 package utils
 
 import (
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"strings"
 
 	"github.com/FleexSecurity/fleex/pkg/models"
 	"gopkg.in/yaml.v2"
 )
 
+// ReadModuleFile loads a module and merges the modules it includes. Included
+// paths are resolved relative to the including module.
 func ReadModuleFile(path string) (*models.Module, error) {
-	data, err := ioutil.ReadFile(path)
+	return loadModule(path, nil)
+}
+
+func loadModule(path string, stack []string) (*models.Module, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range stack {
+		if p == absPath {
+			return nil, fmt.Errorf("module include cycle: %s -> %s", strings.Join(stack, " -> "), absPath)
+		}
+	}
+	stack = append(stack, absPath)
+
+	data, err := ioutil.ReadFile(absPath)
 	if err != nil {
 		return nil, err
 	}
@@ -18,6 +39,30 @@ func ReadModuleFile(path string) (*models.Module, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.Vars == nil {
+		config.Vars = make(map[string]string)
+	}
+
+	for _, ref := range config.Include {
+		refPath := ExpandPath(ref)
+		if !filepath.IsAbs(refPath) {
+			refPath = filepath.Join(filepath.Dir(absPath), refPath)
+		}
+
+		included, err := loadModule(refPath, stack)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range included.Vars {
+			if _, ok := config.Vars[k]; !ok {
+				config.Vars[k] = v
+			}
+		}
+		if len(config.Commands) == 0 {
+			config.Commands = included.Commands
+		}
+	}
 
 	return config, nil
 }
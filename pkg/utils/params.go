@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+)
+
+var varPlaceholderRegex = regexp.MustCompile(`\{vars\.([^{}\s]+)\}`)
+
+// ApplyParams validates vars against the declared params and fills in
+// defaults for the ones that were not provided
+func ApplyParams(params []models.Param, vars map[string]string) error {
+	var errs []error
+
+	for _, param := range params {
+		value, ok := vars[param.Name]
+		if !ok || value == "" {
+			value = param.Default
+		}
+
+		if value == "" {
+			if param.Required {
+				errs = append(errs, fmt.Errorf("missing required param '%s'", param.Name))
+			}
+			continue
+		}
+
+		if err := validateParam(param, value); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		vars[param.Name] = value
+	}
+
+	return errors.Join(errs...)
+}
+
+// ApplyParamFlags sets the KEY:VALUE pairs given with --params in vars,
+// ignoring the ones without a colon
+func ApplyParamFlags(vars map[string]string, flags []string) {
+	for _, flag := range flags {
+		splits := strings.SplitN(flag, ":", 2)
+		if len(splits) == 2 {
+			vars[splits[0]] = splits[1]
+		}
+	}
+}
+
+func validateParam(param models.Param, value string) error {
+	switch param.Type {
+	case "", models.ParamTypeString:
+		return nil
+	case models.ParamTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("param '%s' must be an int, got '%s'", param.Name, value)
+		}
+	case models.ParamTypeFile:
+		path := ExpandPath(value)
+		isDir, err := IsDirectory(path)
+		if err != nil || isDir {
+			return fmt.Errorf("param '%s' must be an existing file, got '%s'", param.Name, value)
+		}
+	case models.ParamTypeList:
+		for _, item := range strings.Split(value, ",") {
+			if strings.TrimSpace(item) == "" {
+				return fmt.Errorf("param '%s' must be a comma-separated list without empty items, got '%s'", param.Name, value)
+			}
+		}
+	default:
+		return fmt.Errorf("param '%s' has unknown type '%s'", param.Name, param.Type)
+	}
+	return nil
+}
+
+// UnresolvedVars returns the names of the {vars.X} placeholders left in text
+func UnresolvedVars(text string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range varPlaceholderRegex.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkUnresolvedVars(text string) error {
+	if names := UnresolvedVars(text); len(names) > 0 {
+		return fmt.Errorf("unresolved vars in '%s': %s", text, strings.Join(names, ", "))
+	}
+	return nil
+}
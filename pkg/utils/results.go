@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+)
+
+const resultOutputFile = "output.txt"
+
+func GetResultsDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "fleex", "results"), nil
+}
+
+// ValidateRunID rejects run IDs that would point outside the results folder
+func ValidateRunID(id string) error {
+	if !fileNameRegex.MatchString(id) {
+		return fmt.Errorf("invalid run id %q: only letters, digits, _ and - are allowed", id)
+	}
+	return nil
+}
+
+// DefaultScope derives the scope of a run from its input file name
+func DefaultScope(input string) string {
+	if input == "" {
+		return "default"
+	}
+	base := filepath.Base(input)
+	return sanitizeSuffix(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// SaveRunRecord stores a run and a copy of its aggregated output. The record
+// ID is filled in if empty.
+func SaveRunRecord(record *models.RunRecord, outputPath string) error {
+	resultsDir, err := GetResultsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(resultsDir, 0755); err != nil {
+		return err
+	}
+
+	if record.ID == "" {
+		base := record.Started.Format("20060102-150405") + "-" + strings.ReplaceAll(sanitizeSuffix(record.Workflow), ".", "_")
+		record.ID = base
+		for i := 2; ; i++ {
+			err := os.Mkdir(filepath.Join(resultsDir, record.ID), 0755)
+			if err == nil {
+				break
+			}
+			if !os.IsExist(err) {
+				return err
+			}
+			record.ID = fmt.Sprintf("%s-%d", base, i)
+		}
+	}
+
+	if err := ValidateRunID(record.ID); err != nil {
+		return err
+	}
+	runDir := filepath.Join(resultsDir, record.ID)
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(outputPath); err == nil && info.Mode().IsRegular() {
+		if _, err := Copy(outputPath, filepath.Join(runDir, resultOutputFile)); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(runDir, "run.json"), data, 0644)
+}
+
+func ReadRunRecord(id string) (*models.RunRecord, error) {
+	if err := ValidateRunID(id); err != nil {
+		return nil, err
+	}
+	resultsDir, err := GetResultsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(resultsDir, id, "run.json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("run not found: %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.RunRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ListRunRecords returns the stored runs, oldest first
+func ListRunRecords() ([]models.RunRecord, error) {
+	resultsDir, err := GetResultsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if !FileExists(resultsDir) {
+		return []models.RunRecord{}, nil
+	}
+
+	entries, err := os.ReadDir(resultsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []models.RunRecord
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		record, err := ReadRunRecord(e.Name())
+		if err != nil {
+			continue
+		}
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Started.Before(records[j].Started) })
+	return records, nil
+}
+
+// PreviousRunRecord returns the latest run of a workflow on a scope started
+// before the given time, or nil if there is none
+func PreviousRunRecord(workflow, scope string, before time.Time) (*models.RunRecord, error) {
+	records, err := ListRunRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.Workflow == workflow && r.Scope == scope && r.Started.Before(before) {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+// CountLines returns the number of lines of a file, 0 if it can't be read
+func CountLines(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return LinesCount(string(data))
+}
+
+// RunOutputPath returns the stored output of a run
+func RunOutputPath(id string) (string, error) {
+	if err := ValidateRunID(id); err != nil {
+		return "", err
+	}
+	resultsDir, err := GetResultsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resultsDir, id, resultOutputFile), nil
+}
+
+func DeleteRunRecord(id string) error {
+	if err := ValidateRunID(id); err != nil {
+		return err
+	}
+	resultsDir, err := GetResultsDir()
+	if err != nil {
+		return err
+	}
+
+	runDir := filepath.Join(resultsDir, id)
+	if !FileExists(filepath.Join(runDir, "run.json")) {
+		return fmt.Errorf("run not found: %s", id)
+	}
+	return os.RemoveAll(runDir)
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"gopkg.in/yaml.v2"
+)
+
+// fileNameRegex matches the names that are used as file or folder names
+var fileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateScheduleName rejects job names that can't be used as file names
+func ValidateScheduleName(name string) error {
+	if !fileNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid job name %q: only letters, digits, _ and - are allowed", name)
+	}
+	return nil
+}
+
+func GetSchedulesDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "fleex", "schedules"), nil
+}
+
+// GetScheduleRunsDir returns the folder holding outputs and history of a job
+func GetScheduleRunsDir(jobName string) (string, error) {
+	if err := ValidateScheduleName(jobName); err != nil {
+		return "", err
+	}
+	schedulesDir, err := GetSchedulesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(schedulesDir, "runs", jobName), nil
+}
+
+func ReadScheduleFile(name string) (*models.ScheduledJob, error) {
+	if err := ValidateScheduleName(name); err != nil {
+		return nil, err
+	}
+	schedulesDir, err := GetSchedulesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(schedulesDir, name+".yaml")
+	if !FileExists(path) {
+		return nil, fmt.Errorf("scheduled job not found: %s", name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.ScheduledJob{}
+	if err := yaml.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func ListSchedules() ([]string, error) {
+	schedulesDir, err := GetSchedulesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if !FileExists(schedulesDir) {
+		return []string{}, nil
+	}
+
+	files, err := os.ReadDir(schedulesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".yaml") {
+			jobs = append(jobs, strings.TrimSuffix(f.Name(), ".yaml"))
+		}
+	}
+	return jobs, nil
+}
+
+func SaveSchedule(job *models.ScheduledJob) error {
+	if err := ValidateScheduleName(job.Name); err != nil {
+		return err
+	}
+	schedulesDir, err := GetSchedulesDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(schedulesDir, 0755); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(schedulesDir, job.Name+".yaml"), data, 0644)
+}
+
+// DeleteSchedule removes a job definition. Its run history is kept.
+func DeleteSchedule(name string) error {
+	if err := ValidateScheduleName(name); err != nil {
+		return err
+	}
+	schedulesDir, err := GetSchedulesDir()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(schedulesDir, name+".yaml")
+	if !FileExists(path) {
+		return fmt.Errorf("scheduled job not found: %s", name)
+	}
+	return os.Remove(path)
+}
+
+func ReadScheduleHistory(jobName string) ([]models.ScheduleRun, error) {
+	runsDir, err := GetScheduleRunsDir(jobName)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(runsDir, "history.json"))
+	if os.IsNotExist(err) {
+		return []models.ScheduleRun{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history []models.ScheduleRun
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// AppendScheduleRun adds a run to the job history and drops the runs (and
+// their outputs) that exceed the retention
+func AppendScheduleRun(run models.ScheduleRun, retention int) error {
+	runsDir, err := GetScheduleRunsDir(run.Job)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return err
+	}
+
+	// The daemon may record a skipped run while the job records its own
+	unlock, err := lockFile(filepath.Join(runsDir, "history.lock"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	history, err := ReadScheduleHistory(run.Job)
+	if err != nil {
+		return err
+	}
+	history = append(history, run)
+	sort.Slice(history, func(i, j int) bool { return history[i].Started.Before(history[j].Started) })
+
+	if retention > 0 && len(history) > retention {
+		for _, old := range history[:len(history)-retention] {
+			if old.Output != "" {
+				os.RemoveAll(filepath.Dir(old.Output))
+			}
+		}
+		history = history[len(history)-retention:]
+	}
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(runsDir, "history.json"), data, 0644)
+}
+
+// AcquireScheduleLock creates the lock file of a job. It returns false if
+// another process holding the lock is still running.
+func AcquireScheduleLock(jobName string) (bool, error) {
+	runsDir, err := GetScheduleRunsDir(jobName)
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return false, err
+	}
+
+	lockFile := filepath.Join(runsDir, "lock")
+	if data, err := os.ReadFile(lockFile); err == nil {
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		if processRunning(pid) {
+			return false, nil
+		}
+		// Stale lock left by a crashed run
+		os.Remove(lockFile)
+	}
+
+	file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(strconv.Itoa(os.Getpid()))
+	return err == nil, err
+}
+
+// lockFile waits until it can create path, removing it when older than a
+// minute as left by a crashed process, and returns the unlock function
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > time.Minute {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func ReleaseScheduleLock(jobName string) {
+	runsDir, err := GetScheduleRunsDir(jobName)
+	if err != nil {
+		return
+	}
+	os.Remove(filepath.Join(runsDir, "lock"))
+}
+
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+)
+
+// Secret references accepted in place of a plaintext value
+const (
+	SecretEnv      = "env:"
+	SecretFile     = "file:"
+	SecretCmd      = "cmd:"
+	SecretKeystore = "keystore:"
+)
+
+// IsSecretRef reports whether value is a secret reference rather than a
+// plaintext secret
+func IsSecretRef(value string) bool {
+	for _, prefix := range []string{SecretEnv, SecretFile, SecretCmd, SecretKeystore} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unlockedKeystore keeps the keystore once unlocked, so the passphrase is
+// only asked once
+var unlockedKeystore struct {
+	sync.Mutex
+	keystore *Keystore
+}
+
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, SecretEnv):
+		name := strings.TrimPrefix(value, SecretEnv)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+
+	case strings.HasPrefix(value, SecretFile):
+		data, err := os.ReadFile(ExpandPath(strings.TrimPrefix(value, SecretFile)))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+
+	case strings.HasPrefix(value, SecretCmd):
+		command := strings.TrimPrefix(value, SecretCmd)
+		var stdout bytes.Buffer
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = &stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s: %v", command, err)
+		}
+		return strings.TrimSpace(stdout.String()), nil
+
+	case strings.HasPrefix(value, SecretKeystore):
+		unlockedKeystore.Lock()
+		defer unlockedKeystore.Unlock()
+		if unlockedKeystore.keystore == nil {
+			if !KeystoreExists() {
+				return "", fmt.Errorf("no keystore, add secrets with fleex secret set")
+			}
+			keystore, err := UnlockKeystore()
+			if err != nil {
+				return "", err
+			}
+			unlockedKeystore.keystore = keystore
+		}
+		name := strings.TrimPrefix(value, SecretKeystore)
+		secret, ok := unlockedKeystore.keystore.Get(name)
+		if !ok {
+			return "", fmt.Errorf("secret %s not in keystore", name)
+		}
+		return secret, nil
+	}
+	return value, nil
+}
+
+// ResolveConfigSecrets replaces the secret references of the config with
+// their values. The references of a provider that is not the selected one
+// are kept when they can't be resolved, ResolveProviderSecrets fails on them
+// if that provider is used.
+func ResolveConfigSecrets(config *models.Config) error {
+	for name, p := range config.Providers {
+		resolved, err := resolveProvider(p)
+		if err != nil {
+			if name == config.Settings.Provider {
+				return fmt.Errorf("providers.%s: %v", name, err)
+			}
+			Log.Debugf("providers.%s: %v", name, err)
+			continue
+		}
+		config.Providers[name] = resolved
+	}
+
+	for i, vm := range config.CustomVMs {
+		password, err := resolveSecret(vm.Password)
+		if err != nil {
+			return fmt.Errorf("custom_vms.%s: %v", vm.InstanceID, err)
+		}
+		config.CustomVMs[i].Password = password
+	}
+
+	for i, n := range config.Notifications {
+		url, err := resolveSecret(n.URL)
+		if err != nil {
+			return fmt.Errorf("notifications.%s: %v", n.Type, err)
+		}
+		config.Notifications[i].URL = url
+	}
+	return nil
+}
+
+// ResolveProviderSecrets resolves the references left in the settings of a
+// provider about to be used. The config is copied if anything is resolved.
+func ResolveProviderSecrets(config *models.Config, name string) (*models.Config, error) {
+	p := config.Providers[name]
+	if !IsSecretRef(p.Token) && !IsSecretRef(p.Password) {
+		return config, nil
+	}
+
+	resolved, err := resolveProvider(p)
+	if err != nil {
+		return nil, fmt.Errorf("providers.%s: %v", name, err)
+	}
+
+	copied := *config
+	copied.Providers = make(map[string]models.Provider, len(config.Providers))
+	for k, v := range config.Providers {
+		copied.Providers[k] = v
+	}
+	copied.Providers[name] = resolved
+	return &copied, nil
+}
+
+func resolveProvider(p models.Provider) (models.Provider, error) {
+	var err error
+	if p.Token, err = resolveSecret(p.Token); err != nil {
+		return p, err
+	}
+	p.Password, err = resolveSecret(p.Password)
+	return p, err
+}
@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/FleexSecurity/fleex/pkg/models"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ValidateShard returns an error if the strategy is unknown. An empty
+// strategy is the default contiguous split.
+func ValidateShard(strategy string) error {
+	if strategy == "" {
+		return nil
+	}
+	for _, s := range models.ShardStrategies {
+		if s == strategy {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown shard strategy %q (supported: %s)", strategy, strings.Join(models.ShardStrategies, ", "))
+}
+
+// ShardFile splits inputFile into the given chunk files using strategy and
+// returns the number of lines written to each chunk. Weights, one per chunk,
+// are only used by the weighted strategy. The input is streamed in one pass
+// (two for contiguous and weighted splits, which need the line count) and
+// chunks are gzipped when compress is set.
+func ShardFile(inputFile string, chunkFiles []string, strategy string, weights []int, compress bool) ([]int, error) {
+	if err := ValidateShard(strategy); err != nil {
+		return nil, err
+	}
+	if len(chunkFiles) == 0 {
+		return nil, fmt.Errorf("no chunks to split %s into", inputFile)
+	}
+
+	assign, err := newShardAssigner(inputFile, strategy, len(chunkFiles), weights)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := os.Open(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	writers := make([]*chunkWriter, len(chunkFiles))
+	defer func() {
+		for _, w := range writers {
+			if w != nil {
+				w.file.Close()
+			}
+		}
+	}()
+	for i, path := range chunkFiles {
+		w, err := newChunkWriter(path, compress)
+		if err != nil {
+			return nil, err
+		}
+		writers[i] = w
+	}
+
+	counts := make([]int, len(chunkFiles))
+	err = ReadLines(input, func(key []byte, write func(io.Writer) error) error {
+		i := assign(string(key))
+		counts[i]++
+		return write(writers[i].buf)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range writers {
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// chunkWriter buffers the lines of a chunk file, optionally gzipped
+type chunkWriter struct {
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+}
+
+func newChunkWriter(path string, compress bool) (*chunkWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &chunkWriter{file: file}
+	if compress {
+		w.gz, _ = gzip.NewWriterLevel(file, gzip.BestSpeed)
+		w.buf = bufio.NewWriter(w.gz)
+	} else {
+		w.buf = bufio.NewWriter(file)
+	}
+	return w, nil
+}
+
+func (w *chunkWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+// newShardAssigner returns a function giving the chunk index of each line,
+// called in input order
+func newShardAssigner(inputFile, strategy string, n int, weights []int) (func(string) int, error) {
+	switch strategy {
+	case models.ShardRoundRobin:
+		next := 0
+		return func(string) int {
+			i := next
+			next = (next + 1) % n
+			return i
+		}, nil
+
+	case models.ShardApex:
+		return func(line string) int {
+			return hashIndex(apexDomain(line), n)
+		}, nil
+
+	case models.ShardSubnet:
+		return func(line string) int {
+			return hashIndex(subnet24(line), n)
+		}, nil
+
+	default:
+		total, err := CountFileLines(inputFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if strategy != models.ShardWeighted || len(weights) != n {
+			weights = make([]int, n)
+			for i := range weights {
+				weights[i] = 1
+			}
+		}
+		sizes := weightedSizes(total, weights)
+
+		chunk, written := 0, 0
+		return func(string) int {
+			for chunk < n-1 && written >= sizes[chunk] {
+				chunk++
+				written = 0
+			}
+			written++
+			return chunk
+		}, nil
+	}
+}
+
+// weightedSizes splits total lines proportionally to weights. The remainder
+// goes to the first chunks, one line each.
+func weightedSizes(total int, weights []int) []int {
+	clamped := make([]int, len(weights))
+	sum := 0
+	for i, w := range weights {
+		if w < 1 {
+			w = 1
+		}
+		clamped[i] = w
+		sum += w
+	}
+
+	sizes := make([]int, len(weights))
+	assigned := 0
+	for i, w := range clamped {
+		sizes[i] = total * w / sum
+		assigned += sizes[i]
+	}
+	for i := 0; assigned < total; i = (i + 1) % len(sizes) {
+		sizes[i]++
+		assigned++
+	}
+	return sizes
+}
+
+func hashIndex(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// apexDomain returns the registered domain of a host or URL line, e.g.
+// example.co.uk for https://a.b.example.co.uk:8443/path
+func apexDomain(line string) string {
+	host := strings.ToLower(strings.TrimSpace(line))
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err == nil && u.Host != "" {
+			host = u.Host
+		}
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimPrefix(strings.TrimSuffix(host, "."), "*.")
+
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if apex, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return apex
+	}
+	return host
+}
+
+// subnet24 returns the /24 network of an IP line (the /48 for IPv6). Lines
+// that are not IPs, CIDRs or ip:port pairs are returned as is.
+func subnet24(line string) string {
+	host := strings.TrimSpace(line)
+	if ip, _, err := net.ParseCIDR(host); err == nil {
+		host = ip.String()
+	} else if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return line
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UserDataLegacy selects the script DigitalOcean boxes ran before user_data
+// templates, which enables password login for op
+const UserDataLegacy = "legacy"
+
+// HardenedUserData disables password authentication and authorizes the fleet
+// key for the configured username. It is written as hardened.yaml by init and
+// is the default of DigitalOcean boxes.
+const HardenedUserData = `#cloud-config
+# Key-only SSH, the default on DigitalOcean. Set "user_data": "hardened.yaml"
+# on another provider to use it there.
+hostname: {vars.BOX_NAME}
+ssh_pwauth: false
+runcmd:
+  - |
+    home=$(getent passwd {vars.USERNAME} | cut -d: -f6)
+    if [ -n "$home" ]; then
+      mkdir -p "$home/.ssh"
+      echo '{vars.PUBLIC_KEY}' >> "$home/.ssh/authorized_keys"
+      chown -R {vars.USERNAME} "$home/.ssh"
+      chmod 700 "$home/.ssh"
+      chmod 600 "$home/.ssh/authorized_keys"
+    fi
+  - sed -i 's/^#\?PasswordAuthentication.*/PasswordAuthentication no/' /etc/ssh/sshd_config
+  - systemctl restart ssh || systemctl restart sshd
+write_files:
+  - path: /etc/fleex
+    content: |
+      fleet={vars.FLEET_NAME}
+      index={vars.INDEX}
+      ttl={vars.TTL}
+`
+
+func GetUserDataDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "fleex", "userdata"), nil
+}
+
+// ReadUserDataTemplate loads a user_data template from a path or by file name
+// from the userdata folder
+func ReadUserDataTemplate(nameOrPath string) (string, error) {
+	path := ExpandPath(nameOrPath)
+	if !FileExists(path) {
+		userDataDir, err := GetUserDataDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(userDataDir, nameOrPath)
+		if !FileExists(path) {
+			return "", fmt.Errorf("user_data template not found: %s", nameOrPath)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
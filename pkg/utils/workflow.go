@@ -10,6 +10,8 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// ReadWorkflowFile loads a workflow by name or path and expands its include
+// and uses directives
 func ReadWorkflowFile(nameOrPath string) (*models.Workflow, error) {
 	var path string
 
@@ -26,19 +28,182 @@ func ReadWorkflowFile(nameOrPath string) (*models.Workflow, error) {
 		}
 	}
 
-	data, err := os.ReadFile(path)
+	return loadWorkflow(path, nil)
+}
+
+func loadWorkflow(path string, stack []string) (*models.Workflow, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range stack {
+		if p == absPath {
+			return nil, fmt.Errorf("workflow include cycle: %s -> %s", strings.Join(stack, " -> "), absPath)
+		}
+	}
+	stack = append(stack, absPath)
+
+	data, err := os.ReadFile(absPath)
 	if err != nil {
 		return nil, err
 	}
 
 	workflow := &models.Workflow{}
 	if err := yaml.Unmarshal(data, workflow); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if workflow.Vars == nil {
+		workflow.Vars = make(map[string]string)
+	}
+
+	var includedSteps []models.WorkflowStep
+	for _, ref := range workflow.Include {
+		included, err := loadWorkflowRef(ref, stack)
+		if err != nil {
+			return nil, err
+		}
+		mergeWorkflow(workflow, included, ref)
+		for _, step := range included.Steps {
+			if step.From == "" {
+				step.From = ref
+			}
+			includedSteps = append(includedSteps, step)
+		}
 	}
 
+	var steps []models.WorkflowStep
+	for _, step := range workflow.Steps {
+		if step.Uses == "" {
+			steps = append(steps, step)
+			continue
+		}
+
+		used, err := loadWorkflowRef(step.Uses, stack)
+		if err != nil {
+			return nil, err
+		}
+		if len(used.Steps) == 0 {
+			return nil, fmt.Errorf("step %s: %s has no steps", step.Name, step.Uses)
+		}
+
+		// Resolve the template params with the values passed through "with".
+		// Params left without a value are handed over to the parent workflow.
+		templateVars := make(map[string]string)
+		for k, v := range used.Vars {
+			templateVars[k] = v
+		}
+		for _, param := range used.Params {
+			if param.Default != "" {
+				templateVars[param.Name] = param.Default
+			}
+		}
+		for k, v := range step.With {
+			templateVars[k] = v
+		}
+		for _, param := range used.Params {
+			if _, ok := templateVars[param.Name]; !ok {
+				workflow.Params = appendParam(workflow.Params, param)
+			}
+		}
+		for i, file := range used.Files {
+			used.Files[i].Source = expandKnownVars(file.Source, templateVars)
+			used.Files[i].Destination = expandKnownVars(file.Destination, templateVars)
+		}
+		for i, cmd := range used.Setup {
+			used.Setup[i] = expandKnownVars(cmd, templateVars)
+		}
+		used.Vars = nil
+		used.Params = nil
+		mergeWorkflow(workflow, used, step.Uses)
+
+		for i, usedStep := range used.Steps {
+			usedStep.Command = expandKnownVars(usedStep.Command, templateVars)
+			if usedStep.From == "" {
+				usedStep.From = step.Uses
+			}
+			if i == len(used.Steps)-1 {
+				if step.Id != "" {
+					usedStep.Id = step.Id
+				}
+				usedStep.Collect = usedStep.Collect || step.Collect
+			}
+			steps = append(steps, usedStep)
+		}
+	}
+	workflow.Steps = append(includedSteps, steps...)
+
 	return workflow, nil
 }
 
+// loadWorkflowRef loads an included workflow. Relative references are
+// resolved against the workflows directory.
+func loadWorkflowRef(ref string, stack []string) (*models.Workflow, error) {
+	path := ref
+	if !filepath.IsAbs(ref) {
+		workflowsDir, err := GetWorkflowsDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(workflowsDir, ref)
+	}
+
+	for _, candidate := range []string{path, path + ".yaml", path + ".yml"} {
+		if isDir, err := IsDirectory(candidate); err == nil && !isDir {
+			return loadWorkflow(candidate, stack)
+		}
+	}
+	return nil, fmt.Errorf("included workflow not found: %s", ref)
+}
+
+// mergeWorkflow merges vars, params, files and setup commands of an included
+// workflow into the parent. Values already defined by the parent win.
+func mergeWorkflow(parent, included *models.Workflow, ref string) {
+	for k, v := range included.Vars {
+		if _, ok := parent.Vars[k]; !ok {
+			parent.Vars[k] = v
+		}
+	}
+	for _, param := range included.Params {
+		parent.Params = appendParam(parent.Params, param)
+	}
+	parent.Files = append(parent.Files, included.Files...)
+
+	for _, cmd := range included.Setup {
+		exists := false
+		for _, existing := range parent.Setup {
+			if existing == cmd {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			parent.Setup = append(parent.Setup, cmd)
+		}
+	}
+
+	parent.Included = append(parent.Included, ref)
+	parent.Included = append(parent.Included, included.Included...)
+}
+
+func appendParam(params []models.Param, param models.Param) []models.Param {
+	for _, p := range params {
+		if p.Name == param.Name {
+			return params
+		}
+	}
+	return append(params, param)
+}
+
+// expandKnownVars replaces the {vars.X} placeholders it has a value for and
+// leaves the others untouched
+func expandKnownVars(text string, vars map[string]string) string {
+	for k, v := range vars {
+		text = strings.ReplaceAll(text, fmt.Sprintf("{vars.%s}", k), v)
+	}
+	return text
+}
+
 func ListWorkflows() ([]string, error) {
 	configDir, err := GetConfigDir()
 	if err != nil {
@@ -94,12 +259,17 @@ func GetWorkflowsDir() (string, error) {
 	return filepath.Join(configDir, "fleex", "workflows"), nil
 }
 
-func ReplaceWorkflowVars(text string, vars map[string]string) string {
+// ReplaceWorkflowVars expands {vars.X}, {INPUT} and {OUTPUT} placeholders.
+// Any {vars.X} left unresolved is returned as an error.
+func ReplaceWorkflowVars(text string, vars map[string]string) (string, error) {
 	for k, v := range vars {
 		placeholder := fmt.Sprintf("{vars.%s}", k)
 		text = strings.ReplaceAll(text, placeholder, v)
 	}
 	text = strings.ReplaceAll(text, "{INPUT}", vars["INPUT"])
 	text = strings.ReplaceAll(text, "{OUTPUT}", vars["OUTPUT"])
-	return text
+	if err := checkUnresolvedVars(text); err != nil {
+		return "", err
+	}
+	return text, nil
 }